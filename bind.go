@@ -20,6 +20,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -30,59 +31,156 @@ type BindUnmarshaler interface {
 	UnmarshalParam(param string) error
 }
 
-type defaultBinder struct{}
+// BinderConfig 默认Binder的配置
+type BinderConfig struct {
+	// BodyLimit 请求体的最大字节数，0表示不限制，路由可以通过MetaBodyLimit单独设置
+	BodyLimit int64
+	// AllowEmptyBody 允许GET, HEAD和DELETE以外的请求没有请求体
+	// 默认返回400错误 "Request body can't be empty"
+	AllowEmptyBody bool
+}
+
+type defaultBinder struct {
+	config BinderConfig
+}
+
+// NewBinder 创建默认的Binder插件，使用UsePlugger替换Twig中默认的Binder
+//
+//	web.UsePlugger(twig.NewBinder(twig.BinderConfig{BodyLimit: 1 << 20}))
+func NewBinder(config BinderConfig) Plugger {
+	return &defaultBinder{config: config}
+}
 
 func (b *defaultBinder) ID() string {
 	return defBinderID
 }
 
 // Bind 绑定当前Ctx到i
+// 依次绑定路由参数(param)、查询参数(query)、请求头(header)和请求体
+// GET和DELETE请求的查询参数按照字段名称绑定没有query标签的字段，其他请求只绑定有query标签的字段
 func (b *defaultBinder) Bind(i interface{}, c Ctx) (err error) {
+	req := c.Req()
+	if isStructPtr(i) {
+		if err = b.BindParams(i, c); err != nil {
+			return
+		}
+		if err = b.bindQuery(i, c, req.Method == http.MethodGet || req.Method == http.MethodDelete); err != nil {
+			return
+		}
+		if err = b.BindHeaders(i, c); err != nil {
+			return
+		}
+	}
+	return b.BindBody(i, c)
+}
+
+// BindParams 绑定路由参数，只绑定有param标签的字段
+func (b *defaultBinder) BindParams(i interface{}, c Ctx) error {
+	r := c.Route()
+	if r == nil || len(r.PNames) == 0 {
+		return nil
+	}
+	params := make(map[string][]string, len(r.PNames))
+	for _, name := range r.PNames {
+		params[name] = []string{c.Param(name)}
+	}
+	if err := b.bindData(i, params, "param", true); err != nil {
+		return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return nil
+}
+
+// BindQuery 绑定查询参数，没有query标签的字段按照字段名称绑定
+func (b *defaultBinder) BindQuery(i interface{}, c Ctx) error {
+	return b.bindQuery(i, c, true)
+}
+
+func (b *defaultBinder) bindQuery(i interface{}, c Ctx, byName bool) error {
+	if err := b.bindData(i, c.QueryParams(), "query", !byName); err != nil {
+		return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return nil
+}
+
+// BindHeaders 绑定请求头，只绑定有header标签的字段
+func (b *defaultBinder) BindHeaders(i interface{}, c Ctx) error {
+	if err := b.bindData(i, c.Req().Header, "header", true); err != nil {
+		return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return nil
+}
+
+// BindBody 根据Content-Type绑定请求体
+// GET, HEAD和DELETE请求的请求体为空时不做处理，其他请求返回400错误，见BinderConfig.AllowEmptyBody
+func (b *defaultBinder) BindBody(i interface{}, c Ctx) (err error) {
 	req := c.Req()
 	if req.ContentLength == 0 {
-		if req.Method == http.MethodGet || req.Method == http.MethodDelete {
-			if err = b.bindData(i, c.QueryParams(), "query"); err != nil {
-				return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
-			}
+		if b.config.AllowEmptyBody || req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodDelete {
 			return
 		}
 		return NewHttpError(http.StatusBadRequest, "Request body can't be empty")
 	}
+	if limit := bodyLimit(c, b.config.BodyLimit); limit > 0 {
+		if err = LimitBody(c, limit); err != nil {
+			return
+		}
+	}
 	ctype := req.Header.Get(HeaderContentType)
 	switch {
-	case strings.HasPrefix(ctype, MIMEApplicationJSON):
-		if err = json.NewDecoder(req.Body).Decode(i); err != nil {
-			if ute, ok := err.(*json.UnmarshalTypeError); ok {
-				return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Unmarshal type error: expected=%v, got=%v, field=%v, offset=%v", ute.Type, ute.Value, ute.Field, ute.Offset)).SetInternal(err)
-			} else if se, ok := err.(*json.SyntaxError); ok {
-				return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Syntax error: offset=%v, error=%v", se.Offset, se.Error())).SetInternal(err)
-			}
-			return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
-		}
-	case strings.HasPrefix(ctype, MIMEApplicationXML), strings.HasPrefix(ctype, MIMETextXML):
-		if err = xml.NewDecoder(req.Body).Decode(i); err != nil {
-			if ute, ok := err.(*xml.UnsupportedTypeError); ok {
-				return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Unsupported type error: type=%v, error=%v", ute.Type, ute.Error())).SetInternal(err)
-			} else if se, ok := err.(*xml.SyntaxError); ok {
-				return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Syntax error: line=%v, error=%v", se.Line, se.Error())).SetInternal(err)
-			}
-			return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
-		}
 	case strings.HasPrefix(ctype, MIMEApplicationForm), strings.HasPrefix(ctype, MIMEMultipartForm):
 		params, err := c.FormParams()
 		if err != nil {
-			return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			return decodeError(err)
 		}
-		if err = b.bindData(i, params, "form"); err != nil {
+		if err = b.bindData(i, params, "form", false); err != nil {
 			return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
 		}
 	default:
-		return ErrUnsupportedMediaType
+		// 其他类型使用Codecs中注册的Codec解析，默认支持JSON和XML
+		codec := GetCodec(ctype, c)
+		if codec == nil {
+			return ErrUnsupportedMediaType
+		}
+		if err = codec.Decode(req.Body, i); err != nil {
+			return decodeError(err)
+		}
 	}
 	return
 }
 
-func (b *defaultBinder) bindData(ptr interface{}, data map[string][]string, tag string) error {
+// decodeError 转换Codec的解析错误
+func decodeError(err error) *HttpError {
+	// 例如请求体超过限制
+	var he *HttpError
+	if errors.As(err, &he) {
+		return he
+	}
+
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Unmarshal type error: expected=%v, got=%v, field=%v, offset=%v", e.Type, e.Value, e.Field, e.Offset)).SetInternal(err)
+	case *json.SyntaxError:
+		return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Syntax error: offset=%v, error=%v", e.Offset, e.Error())).SetInternal(err)
+	case *xml.UnsupportedTypeError:
+		return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Unsupported type error: type=%v, error=%v", e.Type, e.Error())).SetInternal(err)
+	case *xml.SyntaxError:
+		return NewHttpError(http.StatusBadRequest, fmt.Sprintf("Syntax error: line=%v, error=%v", e.Line, e.Error())).SetInternal(err)
+	}
+	return NewHttpError(http.StatusBadRequest, err.Error()).SetInternal(err)
+}
+
+// bindData 使用data绑定ptr的字段，explicit为true时只绑定有tag标签的字段
+//
+// 支持嵌套的key，a[b]和a.b等价，例如：
+//
+//	filter[status]=open  绑定到map[string]string或者结构体字段filter
+//	items[0].sku=x       绑定到结构体slice字段items
+//	ids=1&ids=2, ids[]=1 绑定到slice字段ids
+//
+// time.Time字段使用layout标签指定格式，默认为RFC3339，layout:"unix"表示Unix秒
+// time.Duration字段使用time.ParseDuration的格式
+// 没有输入并且字段为零值时使用default标签的值，slice的默认值使用逗号分隔
+func (b *defaultBinder) bindData(ptr interface{}, data map[string][]string, tag string, explicit bool) error {
 	typ := reflect.TypeOf(ptr).Elem()
 	val := reflect.ValueOf(ptr).Elem()
 
@@ -90,72 +188,252 @@ func (b *defaultBinder) bindData(ptr interface{}, data map[string][]string, tag
 		return errors.New("binding element must be a struct")
 	}
 
+	return b.bindStruct(val, normalizeKeys(data), "", tag, explicit)
+}
+
+func (b *defaultBinder) bindStruct(val reflect.Value, data map[string][]string, prefix, tag string, explicit bool) error {
+	typ := val.Type()
 	for i := 0; i < typ.NumField(); i++ {
 		typeField := typ.Field(i)
 		structField := val.Field(i)
 		if !structField.CanSet() {
 			continue
 		}
-		structFieldKind := structField.Kind()
 		inputFieldName := typeField.Tag.Get(tag)
 
 		if inputFieldName == "" {
 			inputFieldName = typeField.Name
 			// If tag is nil, we inspect if the field is a struct.
-			if _, ok := bindUnmarshaler(structField); !ok && structFieldKind == reflect.Struct {
-				if err := b.bindData(structField.Addr().Interface(), data, tag); err != nil {
+			if isNestedStruct(structField) {
+				if err := b.bindStruct(structField, data, prefix, tag, explicit); err != nil {
 					return err
 				}
 				continue
 			}
+			if explicit {
+				continue
+			}
 		}
 
-		inputValue, exists := data[inputFieldName]
-		if !exists {
-			// Go json.Unmarshal supports case insensitive binding.  However the
-			// url params are bound case sensitive which is inconsistent.  To
-			// fix this we must check all of the map values in a
-			// case-insensitive search.
-			inputFieldName = strings.ToLower(inputFieldName)
-			for k, v := range data {
-				if strings.ToLower(k) == inputFieldName {
-					inputValue = v
-					exists = true
-					break
-				}
+		key := inputFieldName
+		if prefix != "" {
+			key = prefix + "." + inputFieldName
+		}
+
+		inputValue, exists := lookupValues(data, key)
+		if !exists && !hasNestedKey(data, key) {
+			def, ok := typeField.Tag.Lookup("default")
+			if !ok || !structField.IsZero() {
+				continue
+			}
+			inputValue = []string{def}
+			if structField.Kind() == reflect.Slice {
+				inputValue = strings.Split(def, ",")
 			}
 		}
 
-		if !exists {
-			continue
+		if err := b.setValue(structField, inputValue, data, key, typeField.Tag.Get("layout"), tag, explicit); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
+// setValue 使用values或者data中以key为前缀的嵌套值设置field
+func (b *defaultBinder) setValue(field reflect.Value, values []string, data map[string][]string, key, layout, tag string, explicit bool) error {
+	if len(values) > 0 {
 		// Call this first, in case we're dealing with an alias to an array type
-		if ok, err := unmarshalField(typeField.Type.Kind(), inputValue[0], structField); ok {
-			if err != nil {
+		if ok, err := unmarshalField(field.Kind(), values[0], field); ok {
+			return err
+		}
+
+		switch field.Type() {
+		case timeType:
+			return setTimeField(values[0], layout, field)
+		case durationType:
+			return setDurationField(values[0], field)
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.Ptr:
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return b.setValue(field.Elem(), values, data, key, layout, tag, explicit)
+	case reflect.Slice:
+		return b.setSlice(field, values, data, key, layout, tag, explicit)
+	case reflect.Map:
+		return b.setMap(field, data, key, layout, tag, explicit)
+	case reflect.Struct:
+		if len(values) == 0 {
+			return b.bindStruct(field, data, key, tag, explicit)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return setWithProperType(field.Kind(), values[0], field)
+}
+
+// setSlice 绑定 a=1&a=2 或者 a[0]=1&a[1]=2 形式的slice
+func (b *defaultBinder) setSlice(field reflect.Value, values []string, data map[string][]string, key, layout, tag string, explicit bool) error {
+	if len(values) > 0 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := b.setValue(slice.Index(i), []string{v}, nil, "", layout, tag, explicit); err != nil {
 				return err
 			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	n := -1
+	for k := range data {
+		sub, ok := subKey(k, key)
+		if !ok {
 			continue
 		}
+		i, err := strconv.Atoi(sub)
+		if err != nil || i < 0 {
+			return fmt.Errorf("invalid index in %s", k)
+		}
+		if i >= maxBindIndex {
+			return fmt.Errorf("index out of range in %s", k)
+		}
+		if i > n {
+			n = i
+		}
+	}
+	if n < 0 {
+		return nil
+	}
 
-		numElems := len(inputValue)
-		if structFieldKind == reflect.Slice && numElems > 0 {
-			sliceOf := structField.Type().Elem().Kind()
-			slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
-			for j := 0; j < numElems; j++ {
-				if err := setWithProperType(sliceOf, inputValue[j], slice.Index(j)); err != nil {
-					return err
-				}
-			}
-			val.Field(i).Set(slice)
-		} else if err := setWithProperType(typeField.Type.Kind(), inputValue[0], structField); err != nil {
+	slice := reflect.MakeSlice(field.Type(), n+1, n+1)
+	for i := 0; i <= n; i++ {
+		ek := key + "." + strconv.Itoa(i)
+		vs, ok := lookupValues(data, ek)
+		if !ok && !hasNestedKey(data, ek) {
+			continue
+		}
+		if err := b.setValue(slice.Index(i), vs, data, ek, layout, tag, explicit); err != nil {
 			return err
+		}
+	}
+	field.Set(slice)
+	return nil
+}
 
+// setMap 绑定 m[a]=1&m[b]=2 形式的map，map的key必须为string类型
+func (b *defaultBinder) setMap(field reflect.Value, data map[string][]string, key, layout, tag string, explicit bool) error {
+	typ := field.Type()
+	if typ.Key().Kind() != reflect.String {
+		return errors.New("map key must be a string")
+	}
+
+	m := field
+	if m.IsNil() {
+		m = reflect.MakeMap(typ)
+	}
+	for k := range data {
+		sub, ok := subKey(k, key)
+		if !ok {
+			continue
+		}
+		ek := k[:len(key)+1+len(sub)] // 保留data中key的大小写
+		vs, _ := lookupValues(data, ek)
+		ev := reflect.New(typ.Elem()).Elem()
+		if err := b.setValue(ev, vs, data, ek, layout, tag, explicit); err != nil {
+			return err
 		}
+		m.SetMapIndex(reflect.ValueOf(sub).Convert(typ.Key()), ev)
+	}
+	if m.Len() > 0 {
+		field.Set(m)
 	}
 	return nil
 }
 
+// maxBindIndex 绑定slice时允许的最大下标，避免恶意请求分配过大的slice
+const maxBindIndex = 1000
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func isNestedStruct(field reflect.Value) bool {
+	if field.Kind() != reflect.Struct || field.Type() == timeType {
+		return false
+	}
+	_, ok := bindUnmarshaler(field)
+	return !ok
+}
+
+// normalizeKeys 将 a[b][0] 和 a[] 形式的key转换为 a.b.0 和 a
+func normalizeKeys(data map[string][]string) map[string][]string {
+	found := false
+	for k := range data {
+		if strings.IndexByte(k, '[') >= 0 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return data
+	}
+
+	norm := make(map[string][]string, len(data))
+	for k, v := range data {
+		if strings.IndexByte(k, '[') >= 0 {
+			k = strings.TrimSuffix(k, "[]")
+			k = strings.NewReplacer("][", ".", "[", ".", "]", "").Replace(k)
+		}
+		norm[k] = append(norm[k], v...)
+	}
+	return norm
+}
+
+// lookupValues 查找key对应的值，找不到时忽略大小写再查找一次
+func lookupValues(data map[string][]string, key string) ([]string, bool) {
+	if v, ok := data[key]; ok {
+		return v, true
+	}
+	// Go json.Unmarshal supports case insensitive binding.  However the
+	// url params are bound case sensitive which is inconsistent.  To
+	// fix this we must check all of the map values in a
+	// case-insensitive search.
+	for k, v := range data {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// subKey 返回k中紧跟在prefix之后的一级key，例如 subKey("a.b.c", "a") 返回 "b"
+func subKey(k, prefix string) (string, bool) {
+	if len(k) <= len(prefix)+1 || k[len(prefix)] != '.' || !strings.EqualFold(k[:len(prefix)], prefix) {
+		return "", false
+	}
+	sub := k[len(prefix)+1:]
+	if i := strings.IndexByte(sub, '.'); i >= 0 {
+		sub = sub[:i]
+	}
+	return sub, true
+}
+
+func hasNestedKey(data map[string][]string, key string) bool {
+	for k := range data {
+		if _, ok := subKey(k, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func setWithProperType(valueKind reflect.Kind, val string, structField reflect.Value) error {
 	// But also call it here, in case we're dealing with an array of BindUnmarshalers
 	if ok, err := unmarshalField(valueKind, val, structField); ok {
@@ -270,6 +548,43 @@ func setBoolField(value string, field reflect.Value) error {
 	return err
 }
 
+func setTimeField(value, layout string, field reflect.Value) error {
+	if value == "" {
+		field.Set(reflect.Zero(timeType))
+		return nil
+	}
+	var t time.Time
+	switch layout {
+	case "unix":
+		sec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		t = time.Unix(sec, 0)
+	case "":
+		layout = time.RFC3339
+		fallthrough
+	default:
+		var err error
+		if t, err = time.Parse(layout, value); err != nil {
+			return err
+		}
+	}
+	field.Set(reflect.ValueOf(t))
+	return nil
+}
+
+func setDurationField(value string, field reflect.Value) error {
+	if value == "" {
+		value = "0"
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil {
+		field.SetInt(int64(d))
+	}
+	return err
+}
+
 func setFloatField(value string, bitSize int, field reflect.Value) error {
 	if value == "" {
 		value = "0.0"
@@ -281,8 +596,41 @@ func setFloatField(value string, bitSize int, field reflect.Value) error {
 	return err
 }
 
+func isStructPtr(i interface{}) bool {
+	t := reflect.TypeOf(i)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// binder 获取注册的默认Binder，被替换为其他实现时使用默认配置
+func binder(c Ctx) *defaultBinder {
+	if b, ok := GetPlugger(defBinderID, c).(*defaultBinder); ok {
+		return b
+	}
+	return &defaultBinder{}
+}
+
 // Bind 绑定ctx到变量
 func Bind(i interface{}, c Ctx) error {
 	binder := GetBinder(defBinderID, c)
 	return binder.Bind(i, c)
 }
+
+// BindQuery 只绑定查询参数，见defaultBinder#BindQuery
+func BindQuery(i interface{}, c Ctx) error {
+	return binder(c).BindQuery(i, c)
+}
+
+// BindParams 只绑定路由参数，字段使用param标签，如 `param:"id"`
+func BindParams(i interface{}, c Ctx) error {
+	return binder(c).BindParams(i, c)
+}
+
+// BindHeaders 只绑定请求头，字段使用header标签，如 `header:"X-Tenant"`
+func BindHeaders(i interface{}, c Ctx) error {
+	return binder(c).BindHeaders(i, c)
+}
+
+// BindBody 只绑定请求体
+func BindBody(i interface{}, c Ctx) error {
+	return binder(c).BindBody(i, c)
+}
@@ -0,0 +1,61 @@
+package twig
+
+import (
+	"io"
+)
+
+// MetaBodyLimit 路由的请求体最大字节数(int64或者int)，默认Binder绑定请求体时使用
+//
+//	twig.Config(r).Post("/upload", upload).SetMeta(twig.MetaBodyLimit, int64(10<<20))
+const MetaBodyLimit = "twig.body_limit"
+
+// limitedBody 限制读取字节数的请求体，超过限制时返回413错误
+type limitedBody struct {
+	io.ReadCloser
+	n int64 // 剩余可以读取的字节数
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.n < 0 {
+		return 0, ErrStatusRequestEntityTooLarge
+	}
+	// 多读一个字节，用于判断是否超过限制
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+	n, err = b.ReadCloser.Read(p)
+	if int64(n) <= b.n {
+		b.n -= int64(n)
+		return
+	}
+	n, b.n = int(b.n), -1
+	return n, ErrStatusRequestEntityTooLarge
+}
+
+// LimitBody 限制请求体最多读取limit字节
+// Content-Length超过limit时立即返回413错误，否则读取超过limit时返回413错误
+// 多次调用时较小的limit生效
+func LimitBody(c Ctx, limit int64) error {
+	req := c.Req()
+	if req.ContentLength > limit {
+		return ErrStatusRequestEntityTooLarge
+	}
+	if lb, ok := req.Body.(*limitedBody); ok && lb.n <= limit {
+		return nil
+	}
+	if req.Body != nil {
+		req.Body = &limitedBody{ReadCloser: req.Body, n: limit}
+	}
+	return nil
+}
+
+// bodyLimit 当前路由的请求体限制，没有设置时使用def
+func bodyLimit(c Ctx, def int64) int64 {
+	switch limit := c.Meta(MetaBodyLimit).(type) {
+	case int64:
+		return limit
+	case int:
+		return int64(limit)
+	}
+	return def
+}
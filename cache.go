@@ -0,0 +1,221 @@
+package twig
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETag 根据data生成强ETag，结果包含引号，例如 "xyz"
+func ETag(data []byte) string {
+	sum := sha1.Sum(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+}
+
+// WeakETag 根据data生成弱ETag，例如 W/"xyz"
+// 内容语义相同但字节不完全相同时(例如压缩后)使用
+func WeakETag(data []byte) string {
+	return "W/" + ETag(data)
+}
+
+// quoteETag 没有引号的etag加上引号
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strconv.Quote(etag)
+}
+
+// SetETag 设置响应的ETag，没有引号时自动加上引号
+func SetETag(c Ctx, etag string) {
+	c.Resp().Header().Set(HeaderETag, quoteETag(etag))
+}
+
+// SetLastModified 设置响应的Last-Modified
+func SetLastModified(c Ctx, modtime time.Time) {
+	c.Resp().Header().Set(HeaderLastModified, modtime.UTC().Format(http.TimeFormat))
+}
+
+// matchETag 判断If-Match或者If-None-Match中的列表是否包含etag，weak为true时使用弱比较
+func matchETag(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if weak {
+			if strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if t == etag && !strings.HasPrefix(t, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedSince modtime是否晚于header中的时间，header无效时ok为false
+func modifiedSince(header string, modtime time.Time) (modified bool, ok bool) {
+	if header == "" || modtime.IsZero() || modtime.Equal(time.Unix(0, 0)) {
+		return false, false
+	}
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return false, false
+	}
+	return modtime.Truncate(time.Second).After(t), true
+}
+
+// EvalPreconditions 按照RFC 7232的顺序对请求r检查If-Match, If-Unmodified-Since,
+// If-None-Match和If-Modified-Since，etag为当前资源的ETag，modtime为修改时间，可以为空
+// 返回304或者412表示条件请求的结果，返回0表示需要继续处理
+func EvalPreconditions(r *http.Request, etag string, modtime time.Time) int {
+	etag = quoteETag(etag)
+
+	if im := r.Header.Get(HeaderIfMatch); im != "" {
+		if !matchETag(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if modified, ok := modifiedSince(r.Header.Get(HeaderIfUnmodifiedSince), modtime); ok && modified {
+		return http.StatusPreconditionFailed
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if inm := r.Header.Get(HeaderIfNoneMatch); inm != "" {
+		if matchETag(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if safe {
+		if modified, ok := modifiedSince(r.Header.Get(HeaderIfModifiedSince), modtime); ok && !modified {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// CheckPreconditions 设置ETag和Last-Modified并检查条件请求
+// 资源没有修改时输出304并返回true，条件不满足时返回412错误
+//
+//	if done, err := c.CheckPreconditions(etag, article.Updated); done || err != nil {
+//		return err
+//	}
+func CheckPreconditions(c Ctx, etag string, modtime time.Time) (bool, error) {
+	if etag != "" {
+		SetETag(c, etag)
+	}
+	if !modtime.IsZero() {
+		SetLastModified(c, modtime)
+	}
+
+	switch EvalPreconditions(c.Req(), etag, modtime) {
+	case http.StatusNotModified:
+		notModified(c.Resp())
+		return true, nil
+	case http.StatusPreconditionFailed:
+		return true, ErrPreconditionFailed
+	}
+	return false, nil
+}
+
+// notModified 输出304，删除和响应体相关的Header
+func notModified(resp *ResponseWrap) {
+	h := resp.Header()
+	h.Del(HeaderContentType)
+	h.Del(HeaderContentLength)
+	h.Del(HeaderContentEncoding)
+	resp.WriteHeader(http.StatusNotModified)
+}
+
+// BlobWithETag 使用bs生成强ETag并检查条件请求，没有命中时输出bs
+// code不是2xx时直接输出
+func BlobWithETag(c Ctx, code int, contentType string, bs []byte) error {
+	if code >= 200 && code < 300 {
+		if done, err := CheckPreconditions(c, ETag(bs), time.Time{}); done || err != nil {
+			return err
+		}
+	}
+	return Byte(c.Resp(), code, contentType, bs)
+}
+
+// JSONWithETag 使用JSON Codec编码v，并以编码的结果生成ETag，见BlobWithETag
+func JSONWithETag(c Ctx, code int, v interface{}) error {
+	codec := codecOr(MIMEApplicationJSON, JSONCodec, c)
+	buf := new(bytes.Buffer)
+	if err := codec.Encode(buf, v); err != nil {
+		return err
+	}
+	return BlobWithETag(c, code, codec.ContentType(), buf.Bytes())
+}
+
+// CacheControl Cache-Control构造器
+//
+//	twig.NewCacheControl().Public().MaxAge(time.Hour).Immutable().String()
+//	// public, max-age=3600, immutable
+type CacheControl struct {
+	directives []string
+}
+
+// NewCacheControl 创建空的CacheControl
+func NewCacheControl() *CacheControl {
+	return &CacheControl{}
+}
+
+func (cc *CacheControl) add(d string) *CacheControl {
+	cc.directives = append(cc.directives, d)
+	return cc
+}
+
+func (cc *CacheControl) addSeconds(d string, t time.Duration) *CacheControl {
+	if t < 0 {
+		t = 0
+	}
+	return cc.add(d + "=" + strconv.FormatInt(int64(t/time.Second), 10))
+}
+
+func (cc *CacheControl) Public() *CacheControl          { return cc.add("public") }
+func (cc *CacheControl) Private() *CacheControl         { return cc.add("private") }
+func (cc *CacheControl) NoCache() *CacheControl         { return cc.add("no-cache") }
+func (cc *CacheControl) NoStore() *CacheControl         { return cc.add("no-store") }
+func (cc *CacheControl) NoTransform() *CacheControl     { return cc.add("no-transform") }
+func (cc *CacheControl) MustRevalidate() *CacheControl  { return cc.add("must-revalidate") }
+func (cc *CacheControl) ProxyRevalidate() *CacheControl { return cc.add("proxy-revalidate") }
+func (cc *CacheControl) Immutable() *CacheControl       { return cc.add("immutable") }
+
+// MaxAge max-age，精确到秒
+func (cc *CacheControl) MaxAge(d time.Duration) *CacheControl {
+	return cc.addSeconds("max-age", d)
+}
+
+// SMaxAge s-maxage，共享缓存使用
+func (cc *CacheControl) SMaxAge(d time.Duration) *CacheControl {
+	return cc.addSeconds("s-maxage", d)
+}
+
+func (cc *CacheControl) StaleWhileRevalidate(d time.Duration) *CacheControl {
+	return cc.addSeconds("stale-while-revalidate", d)
+}
+
+func (cc *CacheControl) StaleIfError(d time.Duration) *CacheControl {
+	return cc.addSeconds("stale-if-error", d)
+}
+
+func (cc *CacheControl) String() string {
+	return strings.Join(cc.directives, ", ")
+}
+
+// SetCacheControl 设置响应的Cache-Control
+//
+//	twig.SetCacheControl(c, twig.NewCacheControl().Private().NoCache())
+func SetCacheControl(c Ctx, cc *CacheControl) {
+	c.Resp().Header().Set(HeaderCacheControl, cc.String())
+}
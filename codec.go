@@ -0,0 +1,156 @@
+package twig
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+)
+
+const codecsID = "_twig_codecs_"
+
+// Codec 编解码器，Bind使用Decode解析请求体，Ctx#Render使用Encode输出响应
+type Codec interface {
+	// ContentType 输出时使用的Content-Type
+	ContentType() string
+	Encode(io.Writer, interface{}) error
+	Decode(io.Reader, interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return MIMEApplicationJSONCharsetUTF8
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return MIMEApplicationXMLCharsetUTF8
+}
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// JSONCodec XMLCodec 默认的编解码器
+var (
+	JSONCodec Codec = jsonCodec{}
+	XMLCodec  Codec = xmlCodec{}
+)
+
+// Codecs 按照媒体类型注册Codec的插件，第一个注册的Codec为默认Codec
+// 替换默认的Codecs：
+//
+//	codecs := twig.NewCodecs()
+//	codecs.Register(twig.MIMEApplicationMsgpack, msgpackCodec)
+//	web.UsePlugger(codecs)
+type Codecs struct {
+	lock   sync.RWMutex
+	codecs map[string]Codec
+	types  []string
+}
+
+// NewCodecs 创建包含JSON和XML的Codecs
+func NewCodecs() *Codecs {
+	cs := &Codecs{
+		codecs: make(map[string]Codec),
+	}
+	cs.Register(MIMEApplicationJSON, JSONCodec)
+	cs.Register(MIMEApplicationXML, XMLCodec)
+	cs.Register(MIMETextXML, XMLCodec)
+	return cs
+}
+
+func (cs *Codecs) ID() string {
+	return codecsID
+}
+
+// Register 注册mediaType的Codec，已经存在时替换
+func (cs *Codecs) Register(mediaType string, c Codec) {
+	mediaType = baseMediaType(mediaType)
+
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if _, ok := cs.codecs[mediaType]; !ok {
+		cs.types = append(cs.types, mediaType)
+	}
+	cs.codecs[mediaType] = c
+}
+
+// Codec 获取mediaType对应的Codec，mediaType可以包含参数，没有时返回nil
+func (cs *Codecs) Codec(mediaType string) Codec {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.codecs[baseMediaType(mediaType)]
+}
+
+// MediaTypes 按照注册顺序返回媒体类型
+func (cs *Codecs) MediaTypes() []string {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return append([]string(nil), cs.types...)
+}
+
+// baseMediaType 去掉媒体类型的参数并转换为小写
+func baseMediaType(s string) string {
+	if mt, _, err := mime.ParseMediaType(s); err == nil {
+		return mt
+	}
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// GetCodec 获取mediaType对应的Codec，没有注册Codecs插件或者Codec时返回nil
+func GetCodec(mediaType string, c Ctx) Codec {
+	if cs := GetCodecRegistry(codecsID, c); cs != nil {
+		return cs.Codec(mediaType)
+	}
+	return nil
+}
+
+// codecOr 获取mediaType对应的Codec，没有时使用def
+func codecOr(mediaType string, def Codec, c Ctx) Codec {
+	if codec := GetCodec(mediaType, c); codec != nil {
+		return codec
+	}
+	return def
+}
+
+// encodeBlob 先将v编码到缓冲区，成功后再输出状态码和内容
+// 编码失败时响应没有提交，错误处理仍然可以输出错误信息
+func encodeBlob(c Ctx, code int, codec Codec, v interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := codec.Encode(buf, v); err != nil {
+		return err
+	}
+	return c.Blob(code, codec.ContentType(), buf.Bytes())
+}
+
+// defaultMediaType 第一个注册的媒体类型
+func defaultMediaType(c Ctx) string {
+	if cr := GetCodecRegistry(codecsID, c); cr != nil {
+		if types := cr.MediaTypes(); len(types) > 0 {
+			return types[0]
+		}
+	}
+	return MIMEApplicationJSON
+}
@@ -16,6 +16,9 @@ const (
 	//PROPFIND = "PROPFIND"
 	PUT   = http.MethodPut
 	TRACE = http.MethodTrace
+
+	// ANY Conf#Any注册的路由使用的Method，处理没有单独注册的全部方法
+	ANY = "*"
 )
 
 // MIME types
@@ -37,6 +40,7 @@ const (
 	MIMETextPlainCharsetUTF8             = MIMETextPlain + "; " + charsetUTF8
 	MIMEMultipartForm                    = "multipart/form-data"
 	MIMEOctetStream                      = "application/octet-stream"
+	MIMETextEventStream                  = "text/event-stream"
 )
 
 const (
@@ -50,14 +54,20 @@ const (
 	HeaderAcceptEncoding      = "Accept-Encoding"
 	HeaderAllow               = "Allow"
 	HeaderAuthorization       = "Authorization"
+	HeaderCacheControl        = "Cache-Control"
 	HeaderContentDisposition  = "Content-Disposition"
 	HeaderContentEncoding     = "Content-Encoding"
 	HeaderContentLength       = "Content-Length"
 	HeaderContentType         = "Content-Type"
 	HeaderCookie              = "Cookie"
 	HeaderSetCookie           = "Set-Cookie"
+	HeaderETag                = "ETag"
+	HeaderIfMatch             = "If-Match"
+	HeaderIfNoneMatch         = "If-None-Match"
 	HeaderIfModifiedSince     = "If-Modified-Since"
+	HeaderIfUnmodifiedSince   = "If-Unmodified-Since"
 	HeaderLastModified        = "Last-Modified"
+	HeaderLastEventID         = "Last-Event-ID"
 	HeaderLocation            = "Location"
 	HeaderUpgrade             = "Upgrade"
 	HeaderVary                = "Vary"
@@ -113,6 +123,7 @@ var (
 // Errors
 var (
 	ErrUnsupportedMediaType        = NewHttpError(http.StatusUnsupportedMediaType)
+	ErrNotAcceptable               = NewHttpError(http.StatusNotAcceptable)
 	ErrNotFound                    = NewHttpError(http.StatusNotFound)
 	ErrUnauthorized                = NewHttpError(http.StatusUnauthorized)
 	ErrForbidden                   = NewHttpError(http.StatusForbidden)
@@ -124,6 +135,7 @@ var (
 	ErrInternalServerError         = NewHttpError(http.StatusInternalServerError)
 	ErrRequestTimeout              = NewHttpError(http.StatusRequestTimeout)
 	ErrServiceUnavailable          = NewHttpError(http.StatusServiceUnavailable)
+	ErrPreconditionFailed          = NewHttpError(http.StatusPreconditionFailed)
 	ErrValidatorNotRegistered      = errors.New("validator not registered")
 	ErrRendererNotRegistered       = errors.New("renderer not registered")
 	ErrInvalidRedirectCode         = errors.New("invalid redirect status code")
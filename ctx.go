@@ -5,6 +5,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Ctx 接口，用于向Handler传递Twig上下文数据，并提供简化操作完成请求处理
@@ -26,9 +27,21 @@ type Ctx interface {
 	RealIP() string
 	// Path 当前请求的注册路径
 	Path() string
+	// Route 当前请求匹配的路由，没有匹配的路由时为nil
+	Route() *Route
+	// Meta 获取当前路由的元数据，见Conf#SetMeta
+	Meta(string) interface{}
 
 	// Param 获取当前请求的URL参数
 	Param(string) string
+	// HostParam 获取Host等Matcher提取的参数，见Host
+	HostParam(string) string
+	// ParamInt 获取int类型的URL参数，转换失败时返回400错误
+	ParamInt(string) (int, error)
+	// ParamInt64 获取int64类型的URL参数，转换失败时返回400错误
+	ParamInt64(string) (int64, error)
+	// ParamUint64 获取uint64类型的URL参数，转换失败时返回400错误
+	ParamUint64(string) (uint64, error)
 
 	QueryParam(string) string
 	QueryParams() url.Values
@@ -44,6 +57,9 @@ type Ctx interface {
 	Attachment(file, name string) error
 	Inline(file, name string) error
 
+	// Validate 使用默认的Validator校验数据，见StructValidator
+	Validate(interface{}) error
+
 	Get(string) interface{}
 	Set(string, interface{})
 
@@ -53,7 +69,24 @@ type Ctx interface {
 
 	XML(int, interface{}) error
 
+	// HTML 使用Renderer插件输出模版，见TemplateRenderer
+	HTML(int, string, interface{}) error
+
+	// Render 根据Accept选择Codecs中注册的Codec输出，见Codecs
+	Render(int, interface{}) error
+	// Negotiate 根据Accept从给定的媒体类型中选择输出方式，没有给定时使用全部注册的Codec
+	// 数据为View时可以使用Renderer输出HTML，没有可以接受的媒体类型时返回406错误
+	Negotiate(int, interface{}, ...string) error
+
 	Blob(int, string, []byte) error
+
+	// JSONWithETag 以JSON输出，使用内容生成ETag并处理If-None-Match等条件请求
+	JSONWithETag(int, interface{}) error
+	// BlobWithETag 输出bs，使用内容生成ETag并处理If-None-Match等条件请求
+	BlobWithETag(int, string, []byte) error
+	// CheckPreconditions 设置ETag和Last-Modified并检查条件请求
+	// 返回true时已经输出304或者需要返回412错误，见CheckPreconditions
+	CheckPreconditions(string, time.Time) (bool, error)
 	Stream(int, string, io.Reader) error
 
 	String(int, string) error
@@ -63,10 +96,19 @@ type Ctx interface {
 	SetCookie(*http.Cookie)
 	Cookies() []*http.Cookie
 
+	// Upgrade 将请求升级为WebSocket连接，见Upgrader
+	Upgrade() (*WSConn, error)
+
+	// SSE 开始输出Server-Sent Events
+	SSE() (*SSE, error)
+
 	NoContent() error
 	Error(error)
 	Redirect(int, string) error
 
+	// URL 根据路由名称生成URL，见Twig#URL
+	URL(string, ...interface{}) string
+
 	Twig() *Twig
 
 	Logger() Logger
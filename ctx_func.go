@@ -46,6 +46,18 @@ func String(w http.ResponseWriter, code int, str string) (err error) {
 	return
 }
 
+// AddVary 向Vary中加入field，已经存在时不重复加入
+func AddVary(h http.Header, field string) {
+	for _, v := range h.Values(HeaderVary) {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add(HeaderVary, field)
+}
+
 func IsWebSocket(r *http.Request) bool {
 	upgrade := r.Header.Get(HeaderUpgrade)
 	return strings.ToLower(upgrade) == "websocket"
@@ -1,6 +1,7 @@
 package twig
 
 import (
+	"encoding/xml"
 	"fmt"
 	"net/http"
 )
@@ -34,6 +35,17 @@ func (e *HttpError) SetInternal(err error) *HttpError {
 	return e
 }
 
+// paramError URL参数转换错误
+func paramError(name string, err error) *HttpError {
+	return NewHttpError(http.StatusBadRequest, fmt.Sprintf("invalid param %s", name)).SetInternal(err)
+}
+
+// errorMsg 默认的错误输出，JSON为 {"msg": "..."}，XML为 <error><msg>...</msg></error>
+type errorMsg struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Msg     string   `json:"msg" xml:"msg"`
+}
+
 type HttpErrorHandler func(error, Ctx)
 
 // 默认的错误处理
@@ -53,14 +65,19 @@ var DefaultHttpErrorHandler = func(err error, c Ctx) {
 	}
 
 	if m, ok := msg.(string); ok {
-		msg = map[string]string{"msg": m}
+		msg = &errorMsg{Msg: m}
 	}
 
 	if !c.Resp().Committed {
 		if c.Req().Method == http.MethodHead {
-			err = c.NoContent()
-		} else {
-			err = c.JSON(code, msg)
+			WriteHeaderCode(c.Resp(), code) // HEAD 只输出状态码
+			err = nil
+		} else if err = c.Render(code, msg); err != nil { // 根据Accept选择输出格式
+			// msg无法使用协商的格式编码时(例如map不能编码为XML)使用JSON输出
+			c.Logger().Println(err)
+			if err = c.JSON(code, msg); err != nil {
+				err = c.JSON(code, &errorMsg{Msg: http.StatusText(code)})
+			}
 		}
 		if err != nil {
 			c.Logger().Println(err)
@@ -1,5 +1,5 @@
 module github.com/twiglab/twig
 
-go 1.12
+go 1.16
 
 require github.com/dgrijalva/jwt-go v3.2.0+incompatible
@@ -32,16 +32,45 @@ func Merge(handler HandlerFunc, m []MiddlewareFunc) HandlerFunc {
 	return h
 }
 
-// NotFoundHandler 全局404处理方法
+// NotFoundHandler 默认的404处理方法
+// RadixTree创建时使用，请通过RadixTree或者Assembler设置各自的404处理
 var NotFoundHandler = func(c Ctx) error {
 	return ErrNotFound
 }
 
-// MethodNotAllowedHandler 全局405处理方法
+// MethodNotAllowedHandler 默认的405处理方法
+// RadixTree创建时使用，请通过RadixTree或者Assembler设置各自的405处理
 var MethodNotAllowedHandler = func(c Ctx) error {
 	return ErrMethodNotAllowed
 }
 
+// optionsHandler 自动处理OPTIONS请求
+var optionsHandler = func(c Ctx) error {
+	return c.NoContent()
+}
+
+// allowHandler 设置Allow头后执行h
+func allowHandler(allow string, h HandlerFunc) HandlerFunc {
+	return func(c Ctx) error {
+		c.Resp().Header().Set(HeaderAllow, allow)
+		return h(c)
+	}
+}
+
+// DiscardBody 执行h，并丢弃h输出的响应体，Header和状态码保持不变
+// 用于使用GET的Handler处理HEAD请求
+func DiscardBody(h HandlerFunc) HandlerFunc {
+	return func(c Ctx) error {
+		resp := c.Resp()
+		w := resp.Writer
+		resp.Writer = discardWriter{w}
+		defer func() {
+			resp.Writer = w
+		}()
+		return h(c)
+	}
+}
+
 // Static 处理静态文件的HandlerFunc
 func Static(r string) HandlerFunc {
 	root := path.Clean(r)
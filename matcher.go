@@ -0,0 +1,166 @@
+package twig
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Capturer 匹配成功时可以从请求中提取参数的Matcher
+// 提取的参数可以通过Ctx#HostParam获取
+type Capturer interface {
+	Capture(*http.Request) map[string]string
+}
+
+const hostParamsKey = "_twig_host_params_"
+
+// Host 按照请求的Host匹配，忽略端口和大小写
+// pattern 以 . 分隔，支持以下形式:
+//
+//	api.example.com     完全匹配
+//	*.example.com       * 只能出现在开头，匹配一个或多个标签
+//	:tenant.example.com :name 匹配一个标签，并作为参数提取
+func Host(pattern string) Matcher {
+	return &hostMatcher{
+		labels: strings.Split(strings.ToLower(pattern), "."),
+	}
+}
+
+type hostMatcher struct {
+	labels []string
+}
+
+func (m *hostMatcher) Match(req *http.Request) bool {
+	_, ok := m.capture(req)
+	return ok
+}
+
+func (m *hostMatcher) Capture(req *http.Request) map[string]string {
+	params, _ := m.capture(req)
+	return params
+}
+
+func (m *hostMatcher) capture(req *http.Request) (params map[string]string, ok bool) {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	labels := strings.Split(strings.ToLower(host), ".")
+
+	pattern := m.labels
+	if pattern[0] == "*" {
+		if len(labels) < len(pattern) {
+			return nil, false
+		}
+		pattern = pattern[1:]
+		labels = labels[len(labels)-len(pattern):]
+	} else if len(labels) != len(pattern) {
+		return nil, false
+	}
+
+	for i, p := range pattern {
+		if p != "" && p[0] == ':' {
+			if labels[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[p[1:]] = labels[i]
+		} else if p != labels[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// PathPrefix 按照请求路径的前缀匹配
+// 前缀以 / 为边界，例如 /api 匹配 /api 和 /api/users，不匹配 /apix
+func PathPrefix(prefix string) Matcher {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return MatcherFunc(func(req *http.Request) bool {
+		return hasPathPrefix(GetReqPath(req), prefix)
+	})
+}
+
+// Header 请求头name的值等于value时匹配
+func Header(name, value string) Matcher {
+	return MatcherFunc(func(req *http.Request) bool {
+		return req.Header.Get(name) == value
+	})
+}
+
+// HeaderRegexp 请求头name的值满足正则表达式expr时匹配
+func HeaderRegexp(name, expr string) Matcher {
+	re := regexp.MustCompile(expr)
+	return MatcherFunc(func(req *http.Request) bool {
+		return re.MatchString(req.Header.Get(name))
+	})
+}
+
+// And 全部Matcher匹配时匹配
+func And(ms ...Matcher) Matcher {
+	return andMatcher(ms)
+}
+
+type andMatcher []Matcher
+
+func (ms andMatcher) Match(req *http.Request) bool {
+	for _, m := range ms {
+		if !m.Match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ms andMatcher) Capture(req *http.Request) map[string]string {
+	var params map[string]string
+	for _, m := range ms {
+		if c, ok := m.(Capturer); ok {
+			for k, v := range c.Capture(req) {
+				if params == nil {
+					params = make(map[string]string)
+				}
+				params[k] = v
+			}
+		}
+	}
+	return params
+}
+
+// Or 任意一个Matcher匹配时匹配
+func Or(ms ...Matcher) Matcher {
+	return orMatcher(ms)
+}
+
+type orMatcher []Matcher
+
+func (ms orMatcher) Match(req *http.Request) bool {
+	for _, m := range ms {
+		if m.Match(req) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ms orMatcher) Capture(req *http.Request) map[string]string {
+	for _, m := range ms {
+		if m.Match(req) {
+			if c, ok := m.(Capturer); ok {
+				return c.Capture(req)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Not m不匹配时匹配
+func Not(m Matcher) Matcher {
+	return MatcherFunc(func(req *http.Request) bool {
+		return !m.Match(req)
+	})
+}
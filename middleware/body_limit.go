@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/twiglab/twig"
+)
+
+type BodyLimitConfig struct {
+	Skipper Skipper
+	// Limit 请求体的最大长度，例如 "512K", "2M", "1G"，单位为B, K, M, G, T, P
+	Limit string
+}
+
+var DefaultBodyLimitConfig = BodyLimitConfig{
+	Skipper: DefaultSkipper,
+}
+
+// BodyLimit 限制请求体的长度，超过限制时返回413错误
+// 可以用于Twig、Group或者单个路由
+//
+//	web.Use(middleware.BodyLimit("2M"))
+func BodyLimit(limit string) twig.MiddlewareFunc {
+	config := DefaultBodyLimitConfig
+	config.Limit = limit
+	return BodyLimitWithConfig(config)
+}
+
+func BodyLimitWithConfig(config BodyLimitConfig) twig.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultBodyLimitConfig.Skipper
+	}
+
+	limit, err := ParseBytes(config.Limit)
+	if err != nil {
+		panic(fmt.Errorf("body limit: %v", err))
+	}
+
+	return func(next twig.HandlerFunc) twig.HandlerFunc {
+		return func(c twig.Ctx) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			if err := twig.LimitBody(c, limit); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// ParseBytes 解析 "512K", "2M", "1.5G" 形式的字节数，单位为1024进制
+func ParseBytes(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I") // 兼容 KB, KiB
+	if s == "" {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+
+	unit := int64(1)
+	if i := strings.IndexByte("KMGTP", s[len(s)-1]); i >= 0 {
+		unit = 1 << (10 * uint(i+1))
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(n * float64(unit)), nil
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/twiglab/twig"
+)
+
+type ETagConfig struct {
+	Skipper Skipper
+	// Weak 生成弱ETag，响应会被其他中间件修改(例如压缩)时使用
+	Weak bool
+}
+
+var DefaultETagConfig = ETagConfig{
+	Skipper: DefaultSkipper,
+}
+
+// ETag 使用默认配置为GET和HEAD请求的200响应生成ETag
+func ETag() twig.MiddlewareFunc {
+	return ETagWithConfig(DefaultETagConfig)
+}
+
+// ETagWithConfig 缓存GET和HEAD请求的200响应，使用响应内容生成ETag并处理条件请求
+// Handler已经设置ETag时使用Handler的ETag，流式输出(Flush)和Hijack的响应不做处理
+// HEAD请求的响应体由Twig在全部中间件之外丢弃，因此HEAD和GET得到相同的ETag
+//
+//	web.Config().Group("/api", func(r twig.Assembler) {
+//		twig.Config(r).Use(middleware.ETag()).
+//			Get("/articles", listArticles)
+//	})
+func ETagWithConfig(config ETagConfig) twig.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultETagConfig.Skipper
+	}
+
+	return func(next twig.HandlerFunc) twig.HandlerFunc {
+		return func(c twig.Ctx) error {
+			method := c.Req().Method
+			if config.Skipper(c) || method != http.MethodGet && method != http.MethodHead {
+				return next(c)
+			}
+
+			resp := c.Resp()
+			ew := &etagWriter{ResponseWriter: resp.Writer}
+			resp.Writer = ew
+			err := next(c)
+			resp.Writer = ew.ResponseWriter
+
+			if ew.passthrough || !ew.wroteHeader {
+				return err
+			}
+
+			// 缓存的响应还没有输出，重新由ResponseWrap输出
+			resp.Committed = false
+			if err != nil {
+				return err
+			}
+
+			h := resp.Header()
+			etag := h.Get(twig.HeaderETag)
+			if etag == "" {
+				if config.Weak {
+					etag = twig.WeakETag(ew.buf.Bytes())
+				} else {
+					etag = twig.ETag(ew.buf.Bytes())
+				}
+			}
+			var modtime time.Time
+			if lm := h.Get(twig.HeaderLastModified); lm != "" {
+				modtime, _ = http.ParseTime(lm)
+			}
+
+			if done, err := twig.CheckPreconditions(c, etag, modtime); done || err != nil {
+				return err
+			}
+			resp.WriteHeader(ew.code)
+			_, err = resp.Write(ew.buf.Bytes())
+			return err
+		}
+	}
+}
+
+// etagWriter 缓存200响应的Writer，其他状态码直接输出
+type etagWriter struct {
+	http.ResponseWriter
+
+	code        int
+	wroteHeader bool
+	passthrough bool
+	buf         bytes.Buffer
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.code = code
+
+	if code != http.StatusOK {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// Flush 流式输出时放弃生成ETag，输出缓存的内容
+func (w *etagWriter) Flush() {
+	if w.wroteHeader && !w.passthrough {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.code)
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 还没有输出时可以Hijack，例如WebSocket
+func (w *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.wroteHeader {
+		return nil, nil, fmt.Errorf("etag: hijack after response written")
+	}
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("etag: response does not implement http.Hijacker")
+	}
+	w.wroteHeader, w.passthrough = true, true
+	return hj.Hijack()
+}
+
+type CacheControlConfig struct {
+	Skipper Skipper
+	// CacheControl 设置的Cache-Control，必须设置
+	CacheControl *twig.CacheControl
+	// Methods 设置Cache-Control的请求方法，默认 GET, HEAD
+	Methods []string
+}
+
+var DefaultCacheControlConfig = CacheControlConfig{
+	Skipper: DefaultSkipper,
+	Methods: []string{http.MethodGet, http.MethodHead},
+}
+
+// CacheControl 为GET和HEAD请求设置Cache-Control
+//
+//	cc := twig.NewCacheControl().Public().MaxAge(24 * time.Hour)
+//	web.Config().Group("/assets", func(r twig.Assembler) {
+//		twig.Config(r).Use(middleware.CacheControl(cc)).
+//			Get("/*", assets)
+//	})
+func CacheControl(cc *twig.CacheControl) twig.MiddlewareFunc {
+	c := DefaultCacheControlConfig
+	c.CacheControl = cc
+	return CacheControlWithConfig(c)
+}
+
+// CacheControlWithConfig 在Handler执行前设置Cache-Control，Handler可以覆盖
+// Handler返回错误时删除Cache-Control，避免错误响应被缓存
+func CacheControlWithConfig(config CacheControlConfig) twig.MiddlewareFunc {
+	if config.CacheControl == nil {
+		panic("cache control: CacheControl is required")
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultCacheControlConfig.Skipper
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = DefaultCacheControlConfig.Methods
+	}
+	value := config.CacheControl.String()
+
+	return func(next twig.HandlerFunc) twig.HandlerFunc {
+		return func(c twig.Ctx) error {
+			if config.Skipper(c) || !containsMethod(config.Methods, c.Req().Method) {
+				return next(c)
+			}
+
+			h := c.Resp().Header()
+			h.Set(twig.HeaderCacheControl, value)
+			err := next(c)
+			if err != nil && !c.Resp().Committed && h.Get(twig.HeaderCacheControl) == value {
+				h.Del(twig.HeaderCacheControl)
+			}
+			return err
+		}
+	}
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
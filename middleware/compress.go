@@ -0,0 +1,357 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/twiglab/twig"
+)
+
+// Compressor 压缩Writer，需要支持Reset以便复用
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Encoder 使用压缩级别level创建Compressor
+type Encoder func(w io.Writer, level int) (Compressor, error)
+
+// GzipEncoder DeflateEncoder 内置的编码器，deflate使用zlib格式(RFC 7230)
+var (
+	GzipEncoder Encoder = func(w io.Writer, level int) (Compressor, error) {
+		return gzip.NewWriterLevel(w, level)
+	}
+	DeflateEncoder Encoder = func(w io.Writer, level int) (Compressor, error) {
+		return zlib.NewWriterLevel(w, level)
+	}
+)
+
+// NoCompression 不压缩的级别，对应gzip.NoCompression
+// CompressConfig.Level为0时表示使用默认级别，因此需要单独的值
+const NoCompression = -256
+
+type CompressConfig struct {
+	Skipper Skipper
+	// Level 压缩级别，0表示默认的gzip.DefaultCompression，不压缩使用NoCompression
+	Level int
+	// MinLength 小于MinLength字节的响应不压缩，默认1024
+	MinLength int
+	// Encodings 支持的编码，按照优先级排序，默认 gzip, deflate
+	Encodings []string
+	// Encoders 编码器，Encodings中gzip和deflate以外的编码需要在这里提供，例如br
+	Encoders map[string]Encoder
+	// SkipTypes 不压缩的媒体类型前缀，默认为已经压缩的图片、音视频、压缩包和字体
+	SkipTypes []string
+}
+
+var DefaultCompressConfig = CompressConfig{
+	Skipper:   DefaultSkipper,
+	Level:     gzip.DefaultCompression,
+	MinLength: 1024,
+	Encodings: []string{"gzip", "deflate"},
+	SkipTypes: []string{
+		"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+		"video/", "audio/",
+		"application/zip", "application/gzip", "application/x-gzip",
+		"application/x-bzip2", "application/x-7z-compressed", "application/x-rar-compressed",
+		"font/woff", "font/woff2",
+	},
+}
+
+// Compress 使用默认配置压缩响应
+func Compress() twig.MiddlewareFunc {
+	return CompressWithConfig(DefaultCompressConfig)
+}
+
+// CompressWithConfig 根据Accept-Encoding压缩响应
+// HEAD请求同样经过压缩，响应体由Twig在全部中间件之外丢弃，因此HEAD和GET得到相同的响应头
+//
+//	web.Use(middleware.CompressWithConfig(middleware.CompressConfig{
+//		Encodings: []string{"br", "gzip"},
+//		Encoders:  map[string]middleware.Encoder{"br": brotliEncoder},
+//	}))
+func CompressWithConfig(config CompressConfig) twig.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultCompressConfig.Skipper
+	}
+	switch config.Level {
+	case 0:
+		config.Level = DefaultCompressConfig.Level
+	case NoCompression:
+		config.Level = gzip.NoCompression
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = DefaultCompressConfig.MinLength
+	}
+	if len(config.Encodings) == 0 {
+		config.Encodings = DefaultCompressConfig.Encodings
+	}
+	if config.SkipTypes == nil {
+		config.SkipTypes = DefaultCompressConfig.SkipTypes
+	}
+
+	pools := make(map[string]*sync.Pool, len(config.Encodings))
+	for _, enc := range config.Encodings {
+		encoder := config.Encoders[enc]
+		if encoder == nil {
+			switch enc {
+			case "gzip":
+				encoder = GzipEncoder
+			case "deflate":
+				encoder = DeflateEncoder
+			default:
+				panic(fmt.Errorf("compress: no encoder for %s", enc))
+			}
+		}
+		if _, err := encoder(io.Discard, config.Level); err != nil {
+			panic(fmt.Errorf("compress: %v", err))
+		}
+
+		level := config.Level
+		pools[enc] = &sync.Pool{
+			New: func() interface{} {
+				w, _ := encoder(io.Discard, level)
+				return w
+			},
+		}
+	}
+
+	return func(next twig.HandlerFunc) twig.HandlerFunc {
+		return func(c twig.Ctx) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			resp := c.Resp()
+			enc := negotiateEncoding(c.Req().Header.Get(twig.HeaderAcceptEncoding), config.Encodings)
+			if enc == "" {
+				// 其他客户端可能得到压缩的响应
+				twig.AddVary(resp.Header(), twig.HeaderAcceptEncoding)
+				return next(c)
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: resp.Writer,
+				config:         &config,
+				encoding:       enc,
+				pool:           pools[enc],
+			}
+			resp.Writer = cw
+			defer func() {
+				cw.finish()
+				resp.Writer = cw.ResponseWriter
+			}()
+			return next(c)
+		}
+	}
+}
+
+// negotiateEncoding 根据Accept-Encoding选择q值最大的编码，q值相同时按照encodings的顺序
+func negotiateEncoding(accept string, encodings []string) string {
+	if accept == "" {
+		return ""
+	}
+
+	qs := make(map[string]float64)
+	for _, s := range strings.Split(accept, ",") {
+		params := strings.Split(s, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qs[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range encodings {
+		q, ok := qs[enc]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressWriter 压缩响应的Writer
+// 响应不足MinLength字节时先缓存，结束时没有达到MinLength则不压缩
+type compressWriter struct {
+	http.ResponseWriter
+
+	config   *CompressConfig
+	encoding string
+	pool     *sync.Pool
+
+	code        int
+	wroteHeader bool // 已经调用WriteHeader
+	decided     bool // 已经决定是否压缩
+	passthrough bool // 不压缩
+	buf         bytes.Buffer
+	cw          Compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.code = code
+
+	if !w.compressible() {
+		if code == http.StatusNotModified {
+			// 304与对应的200响应使用相同的ETag和Vary
+			w.weakenETag()
+			twig.AddVary(w.Header(), twig.HeaderAcceptEncoding)
+		}
+		w.pass()
+	}
+}
+
+// weakenETag 将强ETag降级为弱ETag，压缩后的内容与原始内容不能共用强ETag(RFC 7232)
+func (w *compressWriter) weakenETag() {
+	h := w.Header()
+	if etag := h.Get(twig.HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set(twig.HeaderETag, "W/"+etag)
+	}
+}
+
+// compressible 根据状态码和响应头判断是否可以压缩
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	switch {
+	case w.code < 200, w.code == http.StatusNoContent, w.code == http.StatusNotModified,
+		w.code == http.StatusPartialContent:
+		return false
+	case h.Get(twig.HeaderContentEncoding) != "", h.Get("Content-Range") != "":
+		return false
+	}
+	if cl := h.Get(twig.HeaderContentLength); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < w.config.MinLength {
+			return false
+		}
+	}
+	ct := strings.ToLower(h.Get(twig.HeaderContentType))
+	for _, t := range w.config.SkipTypes {
+		if strings.HasPrefix(ct, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// pass 不压缩，直接输出
+func (w *compressWriter) pass() {
+	w.decided, w.passthrough = true, true
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+// start 开始压缩，输出响应头和缓存的内容
+func (w *compressWriter) start() error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get(twig.HeaderContentType) == "" && w.buf.Len() > 0 {
+		// 避免Server对压缩后的内容探测类型
+		h.Set(twig.HeaderContentType, http.DetectContentType(w.buf.Bytes()))
+	}
+	w.weakenETag()
+	twig.AddVary(h, twig.HeaderAcceptEncoding)
+	h.Set(twig.HeaderContentEncoding, w.encoding)
+	h.Del(twig.HeaderContentLength)
+	w.ResponseWriter.WriteHeader(w.code)
+
+	w.cw = w.pool.Get().(Compressor)
+	w.cw.Reset(w.ResponseWriter)
+	if w.buf.Len() > 0 {
+		_, err := w.cw.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+	return nil
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.config.MinLength {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush 流式输出时立即开始压缩
+func (w *compressWriter) Flush() {
+	if w.wroteHeader && !w.decided {
+		w.start()
+	}
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 还没有输出时可以Hijack，例如WebSocket
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.wroteHeader {
+		return nil, nil, fmt.Errorf("compress: hijack after response written")
+	}
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("compress: response does not implement http.Hijacker")
+	}
+	w.wroteHeader, w.decided, w.passthrough = true, true, true
+	return hj.Hijack()
+}
+
+// finish 结束压缩，没有达到MinLength时输出未压缩的内容
+func (w *compressWriter) finish() {
+	if w.cw != nil {
+		w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+		return
+	}
+	if w.wroteHeader && !w.decided {
+		// 响应可以压缩，只是没有达到MinLength，同样随Accept-Encoding变化
+		twig.AddVary(w.Header(), twig.HeaderAcceptEncoding)
+		w.pass()
+		if w.buf.Len() > 0 {
+			w.ResponseWriter.Write(w.buf.Bytes())
+		}
+	}
+}
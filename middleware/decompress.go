@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/twiglab/twig"
+)
+
+// Decoder 创建解压Reader
+type Decoder func(io.Reader) (io.ReadCloser, error)
+
+// GzipDecoder DeflateDecoder 内置的解码器，deflate同时支持zlib格式和原始的deflate格式
+var (
+	GzipDecoder Decoder = func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	}
+	DeflateDecoder Decoder = func(r io.Reader) (io.ReadCloser, error) {
+		br := bufio.NewReader(r)
+		if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+)
+
+type DecompressConfig struct {
+	Skipper Skipper
+	// Limit 解压后请求体的最大长度，超过时返回413错误，默认 "32M"
+	Limit string
+	// Decoders 额外的解码器，默认支持gzip, x-gzip和deflate
+	Decoders map[string]Decoder
+}
+
+var DefaultDecompressConfig = DecompressConfig{
+	Skipper: DefaultSkipper,
+	Limit:   "32M",
+}
+
+// Decompress 使用默认配置解压请求体
+//
+//	web.Pre(middleware.Decompress())
+func Decompress() twig.MiddlewareFunc {
+	return DecompressWithConfig(DefaultDecompressConfig)
+}
+
+// DecompressWithConfig 根据Content-Encoding解压请求体，不支持的编码返回415错误
+func DecompressWithConfig(config DecompressConfig) twig.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultDecompressConfig.Skipper
+	}
+	if config.Limit == "" {
+		config.Limit = DefaultDecompressConfig.Limit
+	}
+	limit, err := ParseBytes(config.Limit)
+	if err != nil {
+		panic(fmt.Errorf("decompress: %v", err))
+	}
+
+	decoders := map[string]Decoder{
+		"gzip":    GzipDecoder,
+		"x-gzip":  GzipDecoder,
+		"deflate": DeflateDecoder,
+	}
+	for enc, d := range config.Decoders {
+		decoders[strings.ToLower(enc)] = d
+	}
+	supported := make([]string, 0, len(decoders))
+	for enc := range decoders {
+		supported = append(supported, enc)
+	}
+	sort.Strings(supported)
+	acceptEncoding := strings.Join(supported, ", ")
+
+	return func(next twig.HandlerFunc) twig.HandlerFunc {
+		return func(c twig.Ctx) error {
+			req := c.Req()
+			if config.Skipper(c) || req.Body == nil || req.Body == http.NoBody {
+				return next(c)
+			}
+
+			var encodings []string
+			for _, enc := range strings.Split(req.Header.Get(twig.HeaderContentEncoding), ",") {
+				if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+					encodings = append(encodings, enc)
+				}
+			}
+			if len(encodings) == 0 {
+				return next(c)
+			}
+
+			body := &decompressBody{ReadCloser: req.Body}
+			// 按照编码的相反顺序解压
+			for i := len(encodings) - 1; i >= 0; i-- {
+				decoder, ok := decoders[encodings[i]]
+				if !ok {
+					body.Close()
+					c.Resp().Header().Set(twig.HeaderAcceptEncoding, acceptEncoding)
+					return twig.ErrUnsupportedMediaType
+				}
+				r, err := decoder(body.reader())
+				if err != nil {
+					body.Close()
+					return twig.NewHttpError(http.StatusBadRequest, "invalid "+encodings[i]+" body").SetInternal(err)
+				}
+				body.readers = append(body.readers, r)
+			}
+
+			req.Body = body
+			req.ContentLength = -1
+			req.Header.Del(twig.HeaderContentEncoding)
+			req.Header.Del(twig.HeaderContentLength)
+			defer body.Close()
+
+			if err := twig.LimitBody(c, limit); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// decompressBody 解压后的请求体，关闭时关闭全部解压Reader和原始的请求体
+type decompressBody struct {
+	io.ReadCloser
+	readers []io.ReadCloser
+}
+
+func (b *decompressBody) reader() io.Reader {
+	if n := len(b.readers); n > 0 {
+		return b.readers[n-1]
+	}
+	return b.ReadCloser
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	return b.reader().Read(p)
+}
+
+func (b *decompressBody) Close() error {
+	for i := len(b.readers) - 1; i >= 0; i-- {
+		b.readers[i].Close()
+	}
+	b.readers = nil
+	return b.ReadCloser.Close()
+}
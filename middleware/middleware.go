@@ -8,3 +8,14 @@ type BeforeFunc func(twig.Ctx)
 func DefaultSkipper(_ twig.Ctx) bool {
 	return false
 }
+
+// MetaSkipper 当前路由的元数据key为true时跳过中间件
+//
+//	twig.Config(r).Get("/login", login).SetMeta("public", true)
+//	middleware.JWTWithConfig(middleware.JWTConfig{Skipper: middleware.MetaSkipper("public"), ...})
+func MetaSkipper(key string) Skipper {
+	return func(c twig.Ctx) bool {
+		skip, _ := c.Meta(key).(bool)
+		return skip
+	}
+}
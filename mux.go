@@ -2,6 +2,8 @@ package twig
 
 import (
 	"net/http"
+	"path"
+	"strings"
 )
 
 // Register 接口
@@ -11,6 +13,36 @@ type Register interface {
 	Use(...MiddlewareFunc)
 }
 
+// RouteRegister 接口
+// 注册路由并返回注册成功的路由，用于Conf#SetName和Conf#SetMeta
+type RouteRegister interface {
+	AddRoute(string, string, HandlerFunc, ...MiddlewareFunc) *Route
+}
+
+// addRoute 注册路由，r没有实现RouteRegister时返回nil
+func addRoute(r Register, method, path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	if rr, ok := r.(RouteRegister); ok {
+		return rr.AddRoute(method, path, h, m...)
+	}
+	r.AddHandler(method, path, h, m...)
+	return nil
+}
+
+// ErrorRegister 接口
+// 为Muxer单独设置错误处理，以及404和405的处理
+type ErrorRegister interface {
+	SetHttpErrorHandler(HttpErrorHandler)
+	SetNotFoundHandler(HandlerFunc)
+	SetMethodNotAllowedHandler(HandlerFunc)
+}
+
+// PrefixErrorRegister 接口
+// 为Muxer中某个路径前缀设置404和405的处理，用于Group
+type PrefixErrorRegister interface {
+	SetPrefixNotFoundHandler(string, HandlerFunc)
+	SetPrefixMethodNotAllowedHandler(string, HandlerFunc)
+}
+
 // Lookuper 接口
 // 实现路由查找
 type Lookuper interface {
@@ -23,6 +55,51 @@ type Muxer interface {
 	Register
 }
 
+// hasPathPrefix 以 / 为边界检查path是否以prefix开头，prefix不以 / 结尾
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// ValidMethod 检查method是否为合法的HTTP方法 (RFC 7230 token)
+func ValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for i := 0; i < len(method); i++ {
+		if !isTokenChar(method[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// CleanPath 规范化请求路径，合并重复的 / 并处理 . 和 ..
+// 与path.Clean不同，CleanPath保留尾部的 /
+func CleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cp := path.Clean(p)
+	if p[len(p)-1] == '/' && cp != "/" {
+		cp += "/"
+	}
+	return cp
+}
+
 // 获取当前请求路径
 func GetReqPath(r *http.Request) string {
 	path := r.URL.RawPath
@@ -2,6 +2,8 @@ package twig
 
 import (
 	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
 // Matcher 决定一个路由是否匹配当前请求
@@ -20,34 +22,143 @@ type matchedMux struct {
 	Matcher
 }
 
+// muxTable Twig的路由表，创建后不再修改
+type muxTable struct {
+	ms  []*matchedMux
+	def Muxer
+}
+
+// muxes 管理Twig的全部Muxer
+// 路由表采用copy-on-write的方式更新，运行时可以安全地替换Muxer
 type muxes struct {
-	ms   []*matchedMux
-	def  Muxer
-	twig *Twig
+	table atomic.Value // *muxTable
+	lock  sync.Mutex   // 更新路由表时使用
+	twig  *Twig
+}
+
+func newMuxes(def Muxer, t *Twig) *muxes {
+	m := &muxes{
+		twig: t,
+	}
+	m.table.Store(&muxTable{def: def})
+	return m
+}
+
+func (m *muxes) load() *muxTable {
+	return m.table.Load().(*muxTable)
+}
+
+// update 复制当前路由表，修改后原子地替换
+func (m *muxes) update(f func(*muxTable)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	old := m.load()
+	table := &muxTable{
+		ms:  make([]*matchedMux, len(old.ms)),
+		def: old.def,
+	}
+	copy(table.ms, old.ms)
+
+	f(table)
+	m.table.Store(table)
+}
+
+// Default 返回默认Muxer
+func (m *muxes) Default() Muxer {
+	return m.load().def
 }
 
 func (m *muxes) Lookup(method string, path string, req *http.Request) MuxerCtx {
-	for _, mux := range m.ms {
+	mux, _ := m.match(req)
+	return mux.Lookup(method, path, req)
+}
+
+// match 查找匹配当前请求的Muxer，没有匹配时返回默认Muxer
+func (m *muxes) match(req *http.Request) (Muxer, Matcher) {
+	table := m.load()
+	for _, mux := range table.ms {
 		if mux.Match(req) {
-			return mux.Lookup(method, path, req)
+			return mux.Muxer, mux.Matcher
 		}
 	}
 
-	return m.def.Lookup(method, path, req)
+	return table.def, nil
 }
 
 func (m *muxes) AddHandler(method string, path string, h HandlerFunc, ms ...MiddlewareFunc) {
-	m.def.AddHandler(method, path, h, ms...)
+	m.Default().AddHandler(method, path, h, ms...)
+}
+
+func (m *muxes) AddRoute(method string, path string, h HandlerFunc, ms ...MiddlewareFunc) *Route {
+	return addRoute(m.Default(), method, path, h, ms...)
 }
 
 func (m *muxes) Use(ms ...MiddlewareFunc) {
-	m.def.Use(ms...)
+	m.Default().Use(ms...)
 }
 
 func (m *muxes) AddMuxer(mux Muxer, match Matcher) {
-	mf := &matchedMux{
-		Muxer:   m,
-		Matcher: match,
+	m.update(func(table *muxTable) {
+		table.ms = append(table.ms, &matchedMux{
+			Muxer:   mux,
+			Matcher: match,
+		})
+	})
+}
+
+// SetDefault 替换默认Muxer
+func (m *muxes) SetDefault(mux Muxer) {
+	m.update(func(table *muxTable) {
+		table.def = mux
+	})
+}
+
+// Replace 使用mux替换old，old可以是默认Muxer或者通过AddMuxer增加的Muxer
+func (m *muxes) Replace(old, mux Muxer) (ok bool) {
+	m.update(func(table *muxTable) {
+		if table.def == old {
+			table.def, ok = mux, true
+		}
+		for i, mm := range table.ms {
+			if mm.Muxer == old {
+				table.ms[i] = &matchedMux{
+					Muxer:   mux,
+					Matcher: mm.Matcher,
+				}
+				ok = true
+			}
+		}
+	})
+	return
+}
+
+// Named 依次在默认Muxer和全部matchedMux中根据名称查找路由
+func (m *muxes) Named(name string) *Route {
+	table := m.load()
+	if rt := namedRoute(table.def, name); rt != nil {
+		return rt
+	}
+	for _, mux := range table.ms {
+		if rt := namedRoute(mux.Muxer, name); rt != nil {
+			return rt
+		}
+	}
+	return nil
+}
+
+// Routes 汇总默认Muxer和全部matchedMux的路由
+func (m *muxes) Routes() []*Route {
+	table := m.load()
+
+	var routes []*Route
+	if r, ok := table.def.(Router); ok {
+		routes = append(routes, r.Routes()...)
+	}
+	for _, mux := range table.ms {
+		if r, ok := mux.Muxer.(Router); ok {
+			routes = append(routes, r.Routes()...)
+		}
 	}
-	m.ms = append(m.ms, mf)
+	return routes
 }
@@ -3,7 +3,11 @@
 package twig
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -11,16 +15,82 @@ type kind uint8 // 节点类型
 type children []*node
 
 type methodHandler struct {
-	connect  HandlerFunc
-	delete   HandlerFunc
-	get      HandlerFunc
-	head     HandlerFunc
-	options  HandlerFunc
-	patch    HandlerFunc
-	post     HandlerFunc
-	propfind HandlerFunc
-	put      HandlerFunc
-	trace    HandlerFunc
+	connect  *Route
+	delete   *Route
+	get      *Route
+	head     *Route
+	options  *Route
+	patch    *Route
+	post     *Route
+	propfind *Route
+	put      *Route
+	trace    *Route
+
+	others map[string]*Route // 其他方法，例如WebDAV或者自定义方法
+	any    *Route            // ANY，没有单独注册的方法最后使用
+
+	allow string // 已注册的方法，用于Allow头
+}
+
+// routes 返回全部已注册的路由，常用方法在前，其他方法按名称排序
+func (mh *methodHandler) routes() []*Route {
+	var routes []*Route
+	for _, rt := range [...]*Route{
+		mh.connect, mh.delete, mh.get, mh.head, mh.options,
+		mh.patch, mh.post, mh.propfind, mh.put, mh.trace,
+	} {
+		if rt != nil {
+			routes = append(routes, rt)
+		}
+	}
+
+	others := make([]*Route, 0, len(mh.others))
+	for _, rt := range mh.others {
+		others = append(others, rt)
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].Method < others[j].Method
+	})
+	routes = append(routes, others...)
+
+	if mh.any != nil {
+		routes = append(routes, mh.any)
+	}
+	return routes
+}
+
+// updateAllow 根据已注册的方法重新计算Allow头
+// GET或者ANY存在时自动支持HEAD，OPTIONS总是被支持，ANY存在时包括全部常用方法
+func (mh *methodHandler) updateAllow() {
+	routes := mh.routes()
+	if len(routes) == 0 {
+		mh.allow = ""
+		return
+	}
+
+	allowed := make([]string, 0, len(routes)+len(methods))
+	for _, rt := range routes {
+		if rt != mh.any {
+			allowed = append(allowed, rt.Method)
+		}
+	}
+	if mh.any != nil {
+		for _, m := range methods {
+			if mh.route(m) == nil && m != http.MethodHead && m != http.MethodOptions {
+				allowed = append(allowed, m)
+			}
+		}
+	}
+	if mh.head == nil && (mh.get != nil || mh.any != nil) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	if mh.options == nil {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	sort.SliceStable(allowed, func(i, j int) bool {
+		return methodIndex(allowed[i]) < methodIndex(allowed[j])
+	})
+	mh.allow = strings.Join(allowed, ", ")
 }
 
 const (
@@ -38,6 +108,7 @@ type node struct {
 	ppath         string
 	pnames        []string
 	methodHandler *methodHandler
+	constraint    *constraint // 参数节点的约束
 }
 
 func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath string, pnames []string) *node {
@@ -66,6 +137,19 @@ func (n *node) findChild(l byte, t kind) *node {
 	return nil
 }
 
+// findParamChildWithExpr 查找约束表达式为expr的参数节点
+func (n *node) findParamChildWithExpr(expr string) *node {
+	for _, c := range n.children {
+		if c.label != ':' {
+			continue
+		}
+		if (c.constraint == nil && expr == "") || (c.constraint != nil && c.constraint.expr == expr) {
+			return c
+		}
+	}
+	return nil
+}
+
 func (n *node) findChildWithLabel(l byte) *node {
 	for _, c := range n.children {
 		if c.label == l {
@@ -84,65 +168,179 @@ func (n *node) findChildByKind(t kind) *node {
 	return nil
 }
 
-func (n *node) addHandler(method string, h HandlerFunc) {
+func (n *node) addHandler(method string, rt *Route) {
 	switch method {
 	case http.MethodConnect:
-		n.methodHandler.connect = h
+		n.methodHandler.connect = rt
 	case http.MethodDelete:
-		n.methodHandler.delete = h
+		n.methodHandler.delete = rt
 	case http.MethodGet:
-		n.methodHandler.get = h
+		n.methodHandler.get = rt
 	case http.MethodHead:
-		n.methodHandler.head = h
+		n.methodHandler.head = rt
 	case http.MethodOptions:
-		n.methodHandler.options = h
+		n.methodHandler.options = rt
 	case http.MethodPatch:
-		n.methodHandler.patch = h
+		n.methodHandler.patch = rt
 	case http.MethodPost:
-		n.methodHandler.post = h
+		n.methodHandler.post = rt
 	case PROPFIND:
-		n.methodHandler.propfind = h
+		n.methodHandler.propfind = rt
 	case http.MethodPut:
-		n.methodHandler.put = h
+		n.methodHandler.put = rt
 	case http.MethodTrace:
-		n.methodHandler.trace = h
+		n.methodHandler.trace = rt
+	case ANY:
+		n.methodHandler.any = rt
+	default:
+		if rt == nil {
+			break
+		}
+		if n.methodHandler.others == nil {
+			n.methodHandler.others = make(map[string]*Route)
+		}
+		n.methodHandler.others[method] = rt
 	}
+	n.methodHandler.updateAllow()
 }
 
-func (n *node) findHandler(method string) HandlerFunc {
+func (n *node) findRoute(method string) *Route {
+	return n.methodHandler.route(method)
+}
+
+// route 返回method单独注册的路由
+func (mh *methodHandler) route(method string) *Route {
 	switch method {
 	case http.MethodConnect:
-		return n.methodHandler.connect
+		return mh.connect
 	case http.MethodDelete:
-		return n.methodHandler.delete
+		return mh.delete
 	case http.MethodGet:
-		return n.methodHandler.get
+		return mh.get
 	case http.MethodHead:
-		return n.methodHandler.head
+		return mh.head
 	case http.MethodOptions:
-		return n.methodHandler.options
+		return mh.options
 	case http.MethodPatch:
-		return n.methodHandler.patch
+		return mh.patch
 	case http.MethodPost:
-		return n.methodHandler.post
+		return mh.post
 	case PROPFIND:
-		return n.methodHandler.propfind
+		return mh.propfind
 	case http.MethodPut:
-		return n.methodHandler.put
+		return mh.put
 	case http.MethodTrace:
-		return n.methodHandler.trace
+		return mh.trace
+	case ANY:
+		return mh.any
 	default:
+		return mh.others[method]
+	}
+}
+
+// anyRoute 没有单独注册method时使用的ANY路由
+// HEAD优先使用GET的路由，OPTIONS优先使用自动的OPTIONS处理
+func (n *node) anyRoute(method string) *Route {
+	mh := n.methodHandler
+	if method == http.MethodOptions || (method == http.MethodHead && mh.get != nil) {
 		return nil
 	}
+	return mh.any
+}
+
+// matchedRoute 返回处理method的路由，HEAD没有注册时使用GET的路由，最后使用ANY的路由
+func (n *node) matchedRoute(method string) *Route {
+	rt := n.findRoute(method)
+	if rt == nil && method == http.MethodHead {
+		rt = n.methodHandler.get
+	}
+	if rt == nil {
+		rt = n.anyRoute(method)
+	}
+	return rt
+}
+
+func (n *node) findHandler(method string) HandlerFunc {
+	rt := n.findRoute(method)
+	if rt == nil {
+		rt = n.anyRoute(method)
+	}
+	if rt != nil {
+		return rt.handler
+	}
+	return nil
+}
+
+// findCaseInsensitive 忽略大小写查找路径，返回路由树中的实际路径
+// 参数部分保持请求中的原样
+func (n *node) findCaseInsensitive(search string, buf []byte) ([]byte, bool) {
+	switch n.kind {
+	case pkind:
+		i := strings.IndexByte(search, '/')
+		if i < 0 {
+			i = len(search)
+		}
+		if i == 0 || (n.constraint != nil && !n.constraint.match(search[:i])) {
+			return nil, false
+		}
+		buf = append(buf, search[:i]...)
+		search = search[i:]
+	case akind:
+		return append(buf, search...), n.methodHandler.allow != ""
+	default:
+		pl := len(n.prefix)
+		if len(search) < pl || !strings.EqualFold(search[:pl], n.prefix) {
+			return nil, false
+		}
+		buf = append(buf, n.prefix...)
+		search = search[pl:]
+	}
+
+	if search == "" && n.methodHandler.allow != "" {
+		return buf, true
+	}
+
+	// static > param > any
+	for _, k := range [...]kind{skind, pkind, akind} {
+		for _, c := range n.children {
+			if c.kind != k {
+				continue
+			}
+			if p, ok := c.findCaseInsensitive(search, buf); ok {
+				return p, true
+			}
+		}
+	}
+	return nil, false
 }
 
-func (n *node) checkMethodNotAllowed() HandlerFunc {
-	for _, m := range methods {
-		if h := n.findHandler(m); h != nil {
-			return MethodNotAllowedHandler
+// walk 深度优先遍历当前节点及其子节点
+func (n *node) walk(f func(*node)) {
+	f(n)
+	for _, c := range n.children {
+		c.walk(f)
+	}
+}
+
+// checkMethodNotAllowed 当前节点没有method对应的Handler时使用
+// HEAD 使用GET的Handler，响应体由Twig#ServeHTTP丢弃，OPTIONS 返回Allow头
+// 其他方法返回405，同样设置Allow头
+func (r *RadixTree) checkMethodNotAllowed(n *node, method, path string) HandlerFunc {
+	mh := n.methodHandler
+	if mh.allow == "" {
+		return r.notFound(path)
+	}
+
+	switch method {
+	case http.MethodHead:
+		if mh.get != nil {
+			return mh.get.handler
 		}
+	case http.MethodOptions:
+		return allowHandler(mh.allow, optionsHandler)
 	}
-	return NotFoundHandler
+
+	return allowHandler(mh.allow, r.notAllowed(path))
 }
 
 // RadixTree Twig默认的路由实现
@@ -152,14 +350,60 @@ type RadixTree struct {
 
 	pool     sync.Pool
 	maxParam int
+
+	twig   *Twig
+	params map[string]string // 参数位置 => 参数名称，用于检查冲突
+
+	// Strict 严格模式，注册重复或者冲突的路由时panic
+	// 非严格模式下仅通过Twig#Logger输出警告
+	Strict bool
+
+	// HttpErrorHandler 当前路由的错误处理，为nil时使用Twig#HttpErrorHandler
+	HttpErrorHandler HttpErrorHandler
+	// NotFoundHandler 当前路由的404处理，默认为全局的NotFoundHandler
+	NotFoundHandler HandlerFunc
+	// MethodNotAllowedHandler 当前路由的405处理，默认为全局的MethodNotAllowedHandler
+	MethodNotAllowedHandler HandlerFunc
+
+	fallbacks []*fallback // 按路径前缀设置的404和405处理，前缀长的在前
+
+	names map[string]*Route // 路由名称索引，见Conf#SetName
+
+	// 路径无法匹配时的处理策略，默认为PathStrict
+	TrailingSlash   PathPolicy // 尾部的 /
+	CleanPath       PathPolicy // 重复的 / 以及 . 和 .. ，见CleanPath
+	CaseInsensitive PathPolicy // 忽略大小写再次查找
+
+	// RedirectCode 策略为PathRedirect时使用的状态码
+	// 默认GET和HEAD使用301，其他方法使用308
+	RedirectCode int
+}
+
+// fallback 路径前缀对应的404和405处理
+type fallback struct {
+	prefix     string
+	notFound   HandlerFunc
+	notAllowed HandlerFunc
 }
 
+// PathPolicy 请求路径无法匹配时的处理策略
+type PathPolicy uint8
+
+const (
+	PathStrict   PathPolicy = iota // 严格匹配，返回404
+	PathRedirect                   // 重定向到修正后的路径
+	PathMatch                      // 直接使用修正后的路径匹配
+)
+
 func NewRadixTree() *RadixTree {
 	r := &RadixTree{
 		tree: &node{
 			methodHandler: new(methodHandler),
 		},
 		maxParam: 0,
+
+		NotFoundHandler:         NotFoundHandler,
+		MethodNotAllowedHandler: MethodNotAllowedHandler,
 	}
 
 	r.pool.New = func() interface{} {
@@ -169,6 +413,134 @@ func NewRadixTree() *RadixTree {
 	return r
 }
 
+// SetHttpErrorHandler ErrorRegister#SetHttpErrorHandler
+func (r *RadixTree) SetHttpErrorHandler(h HttpErrorHandler) {
+	r.HttpErrorHandler = h
+}
+
+// SetNotFoundHandler ErrorRegister#SetNotFoundHandler
+func (r *RadixTree) SetNotFoundHandler(h HandlerFunc) {
+	r.NotFoundHandler = h
+}
+
+// SetMethodNotAllowedHandler ErrorRegister#SetMethodNotAllowedHandler
+func (r *RadixTree) SetMethodNotAllowedHandler(h HandlerFunc) {
+	r.MethodNotAllowedHandler = h
+}
+
+// SetPrefixNotFoundHandler PrefixErrorRegister#SetPrefixNotFoundHandler
+func (r *RadixTree) SetPrefixNotFoundHandler(prefix string, h HandlerFunc) {
+	r.fallback(prefix).notFound = h
+}
+
+// SetPrefixMethodNotAllowedHandler PrefixErrorRegister#SetPrefixMethodNotAllowedHandler
+func (r *RadixTree) SetPrefixMethodNotAllowedHandler(prefix string, h HandlerFunc) {
+	r.fallback(prefix).notAllowed = h
+}
+
+// fallback 获取或者创建prefix对应的fallback
+func (r *RadixTree) fallback(prefix string) *fallback {
+	prefix = strings.TrimSuffix(prefix, "/")
+	for _, f := range r.fallbacks {
+		if f.prefix == prefix {
+			return f
+		}
+	}
+
+	f := &fallback{prefix: prefix}
+	r.fallbacks = append(r.fallbacks, f)
+	sort.SliceStable(r.fallbacks, func(i, j int) bool {
+		return len(r.fallbacks[i].prefix) > len(r.fallbacks[j].prefix)
+	})
+	return f
+}
+
+// notFound 返回path对应的404处理
+func (r *RadixTree) notFound(path string) HandlerFunc {
+	for _, f := range r.fallbacks {
+		if f.notFound != nil && hasPathPrefix(path, f.prefix) {
+			return f.notFound
+		}
+	}
+	return r.NotFoundHandler
+}
+
+// notAllowed 返回path对应的405处理
+func (r *RadixTree) notAllowed(path string) HandlerFunc {
+	for _, f := range r.fallbacks {
+		if f.notAllowed != nil && hasPathPrefix(path, f.prefix) {
+			return f.notAllowed
+		}
+	}
+	return r.MethodNotAllowedHandler
+}
+
+// Attach Attacher#Attach
+func (r *RadixTree) Attach(t *Twig) {
+	r.twig = t
+}
+
+// conflict 报告重复或者冲突的路由
+func (r *RadixTree) conflict(format string, v ...interface{}) {
+	msg := fmt.Sprintf("Twig: "+format, v...)
+	if r.Strict {
+		panic(msg)
+	}
+	if r.twig != nil {
+		r.twig.Logger.Println(msg)
+	} else {
+		log.Println(msg)
+	}
+}
+
+// checkParam 检查同一位置的参数是否使用了不同的名称
+// path为去掉参数名称后的路径，以当前参数的 ':' 结尾
+func (r *RadixTree) checkParam(path string, exprs []string, pnames []string, ppath string) {
+	key := path + "\x00" + strings.Join(exprs, "\x00")
+	name := pnames[len(pnames)-1]
+
+	if r.params == nil {
+		r.params = make(map[string]string)
+	}
+	if old, ok := r.params[key]; ok && old != name {
+		r.conflict("conflicting param name :%s in %s, already registered as :%s", name, ppath, old)
+		return
+	}
+	r.params[key] = name
+}
+
+// fork 创建一个新的RadixTree，复制当前RadixTree的设置、中间件、按前缀设置的404/405处理和全部路由
+func (r *RadixTree) fork() *RadixTree {
+	n := NewRadixTree()
+
+	n.twig = r.twig
+	n.Strict = r.Strict
+	n.HttpErrorHandler = r.HttpErrorHandler
+	n.NotFoundHandler = r.NotFoundHandler
+	n.MethodNotAllowedHandler = r.MethodNotAllowedHandler
+	n.TrailingSlash = r.TrailingSlash
+	n.CleanPath = r.CleanPath
+	n.CaseInsensitive = r.CaseInsensitive
+	n.RedirectCode = r.RedirectCode
+
+	n.m = append([]MiddlewareFunc(nil), r.m...)
+	for _, f := range r.fallbacks {
+		fb := *f
+		n.fallbacks = append(n.fallbacks, &fb)
+	}
+	for _, rt := range r.Routes() {
+		nrt := n.Add(rt.Method, rt.Path, rt.handler)
+		if rt.Name != "" {
+			n.setName(nrt, rt.Name)
+		}
+		for k, v := range rt.Meta {
+			nrt.SetMeta(k, v)
+		}
+	}
+
+	return n
+}
+
 func (r *RadixTree) newCtx() *radixTreeCtx {
 	return newRadixTreeCtx(r)
 }
@@ -177,7 +549,20 @@ func (r *RadixTree) releaseCtx(c *radixTreeCtx) {
 	r.pool.Put(c)
 }
 
-func (r *RadixTree) Add(method, path string, h HandlerFunc) {
+func (r *RadixTree) newRoute(method, ppath string, pnames []string, h HandlerFunc) *Route {
+	return &Route{
+		Method:  method,
+		Path:    ppath,
+		PNames:  pnames,
+		Muxer:   r,
+		handler: h,
+	}
+}
+
+func (r *RadixTree) Add(method, path string, h HandlerFunc) *Route {
+	if !ValidMethod(method) {
+		panic("Twig: invalid method " + method)
+	}
 	if path == "" {
 		panic("Twig: path cannot be empty")
 	}
@@ -185,37 +570,44 @@ func (r *RadixTree) Add(method, path string, h HandlerFunc) {
 		path = "/" + path
 	}
 	pnames := []string{} // Param names
+	exprs := []string{}  // Param constraints
 	ppath := path        // Pristine path
 
 	for i, l := 0, len(path); i < l; i++ {
 		if path[i] == ':' {
 			j := i + 1
 
-			r.insert(method, path[:i], nil, skind, "", nil)
-			for ; i < l && path[i] != '/'; i++ {
-			}
+			r.insert(method, path[:i], nil, skind, "", nil, exprs)
+			nameEnd, expr, end := scanParam(path, i)
 
-			pnames = append(pnames, path[j:i])
-			path = path[:j] + path[i:]
+			pnames = append(pnames, path[j:nameEnd])
+			exprs = append(exprs, expr)
+			path = path[:j] + path[end:]
 			i, l = j, len(path)
+			r.checkParam(path[:i], exprs, pnames, ppath)
 
 			if i == l {
-				r.insert(method, path[:i], h, pkind, ppath, pnames)
-				return
+				rt := r.newRoute(method, ppath, pnames, h)
+				r.insert(method, path[:i], rt, pkind, ppath, pnames, exprs)
+				return rt
 			}
-			r.insert(method, path[:i], nil, pkind, "", nil)
+			r.insert(method, path[:i], nil, pkind, "", nil, exprs)
 		} else if path[i] == '*' {
-			r.insert(method, path[:i], nil, skind, "", nil)
+			r.insert(method, path[:i], nil, skind, "", nil, exprs)
 			pnames = append(pnames, "*")
-			r.insert(method, path[:i+1], h, akind, ppath, pnames)
-			return
+			rt := r.newRoute(method, ppath, pnames, h)
+			r.insert(method, path[:i+1], rt, akind, ppath, pnames, exprs)
+			return rt
 		}
 	}
 
-	r.insert(method, path, h, skind, ppath, pnames)
+	rt := r.newRoute(method, ppath, pnames, h)
+	r.insert(method, path, rt, skind, ppath, pnames, exprs)
+	return rt
 }
 
-func (r *RadixTree) insert(method, path string, h HandlerFunc, t kind, ppath string, pnames []string) {
+// insert 插入节点，exprs为路径中参数的约束表达式，按参数出现的顺序排列
+func (r *RadixTree) insert(method, path string, rt *Route, t kind, ppath string, pnames []string, exprs []string) {
 	// 调整url最大参数
 	l := len(pnames)
 	if l > r.maxParam {
@@ -228,6 +620,11 @@ func (r *RadixTree) insert(method, path string, h HandlerFunc, t kind, ppath str
 	}
 	search := path
 
+	// 参数节点的约束表达式
+	exprAt := func(search string) string {
+		return exprs[strings.Count(path[:len(path)-len(search)], ":")]
+	}
+
 	for {
 		sl := len(search)
 		pl := len(cn.prefix)
@@ -245,15 +642,16 @@ func (r *RadixTree) insert(method, path string, h HandlerFunc, t kind, ppath str
 			// At root node
 			cn.label = search[0]
 			cn.prefix = search
-			if h != nil {
+			if rt != nil {
 				cn.kind = t
-				cn.addHandler(method, h)
+				cn.addHandler(method, rt)
 				cn.ppath = ppath
 				cn.pnames = pnames
 			}
 		} else if l < pl {
 			// Split node
 			n := newNode(cn.kind, cn.prefix[l:], cn, cn.children, cn.methodHandler, cn.ppath, cn.pnames)
+			n.constraint = cn.constraint
 
 			// Reset parent node
 			cn.kind = skind
@@ -263,24 +661,33 @@ func (r *RadixTree) insert(method, path string, h HandlerFunc, t kind, ppath str
 			cn.methodHandler = new(methodHandler)
 			cn.ppath = ""
 			cn.pnames = nil
+			cn.constraint = nil
 
 			cn.addChild(n)
 
 			if l == sl {
 				// At parent node
 				cn.kind = t
-				cn.addHandler(method, h)
+				cn.addHandler(method, rt)
 				cn.ppath = ppath
 				cn.pnames = pnames
 			} else {
 				// Create child node
 				n = newNode(t, search[l:], cn, nil, new(methodHandler), ppath, pnames)
-				n.addHandler(method, h)
+				if n.label == ':' {
+					n.constraint = newConstraint(exprAt(search[l:]))
+				}
+				n.addHandler(method, rt)
 				cn.addChild(n)
 			}
 		} else if l < sl {
 			search = search[l:]
-			c := cn.findChildWithLabel(search[0])
+			var c *node
+			if search[0] == ':' {
+				c = cn.findParamChildWithExpr(exprAt(search))
+			} else {
+				c = cn.findChildWithLabel(search[0])
+			}
 			if c != nil {
 				// Go deeper
 				cn = c
@@ -288,12 +695,18 @@ func (r *RadixTree) insert(method, path string, h HandlerFunc, t kind, ppath str
 			}
 			// Create child node
 			n := newNode(t, search, cn, nil, new(methodHandler), ppath, pnames)
-			n.addHandler(method, h)
+			if n.label == ':' {
+				n.constraint = newConstraint(exprAt(search))
+			}
+			n.addHandler(method, rt)
 			cn.addChild(n)
 		} else {
 			// Node already exists
-			if h != nil {
-				cn.addHandler(method, h)
+			if rt != nil {
+				if old := cn.findRoute(method); old != nil {
+					r.conflict("duplicate route %s %s, overwrites %s", method, ppath, old.Path)
+				}
+				cn.addHandler(method, rt)
 				cn.ppath = ppath
 				if len(cn.pnames) == 0 {
 					cn.pnames = pnames
@@ -304,150 +717,227 @@ func (r *RadixTree) insert(method, path string, h HandlerFunc, t kind, ppath str
 	}
 }
 
-func (r *RadixTree) Find(method, path string, ctx *radixTreeCtx) {
-	ctx.path = path
-	//ctx.SetPath(path)
-	cn := r.tree // Current node as root
-
-	var (
-		search  = path
-		child   *node         // Child node
-		n       int           // Param counter
-		nk      kind          // Next kind
-		nn      *node         // Next node
-		ns      string        // Next search
-		pvalues = ctx.pvalues // Use the internal slice so the interface can keep the illusion of a dynamic slice
-	)
-
-	// Search order static > param > any
-	for {
-		if search == "" {
-			break
+// match 匹配search，返回匹配的节点，n为参数节点时参数值已经由父节点取出
+// 查找顺序 static > param > any，参数节点中有约束的节点优先
+// 子节点匹配失败时回溯到下一个候选节点，pi为已经匹配的参数个数
+func (n *node) match(search string, pvalues []string, pi int) *node {
+	if n.kind == skind {
+		if !strings.HasPrefix(search, n.prefix) {
+			return nil
 		}
+		search = search[len(n.prefix):]
+	}
 
-		pl := 0 // Prefix length
-		l := 0  // LCP length
-
-		if cn.label != ':' {
-			sl := len(search)
-			pl = len(cn.prefix)
-
-			// LCP
-			max := pl
-			if sl < max {
-				max = sl
-			}
-			for ; l < max && search[l] == cn.prefix[l]; l++ {
-			}
+	if search == "" {
+		if n.methodHandler.allow != "" {
+			return n
 		}
-
-		if l == pl {
-			// Continue search
-			search = search[l:]
-		} else {
-			cn = nn
-			search = ns
-			if nk == pkind {
-				goto Param
-			} else if nk == akind {
-				goto Any
-			}
-			// Not found
-			return
+		// 通配节点可以匹配空值，由Find处理
+		if c := n.findChildByKind(akind); c != nil {
+			return n
 		}
+		return nil
+	}
 
-		if search == "" {
-			break
+	if c := n.findChild(search[0], skind); c != nil {
+		if m := c.match(search, pvalues, pi); m != nil {
+			return m
 		}
+	}
 
-		// Static node
-		if child = cn.findChild(search[0], skind); child != nil {
-			// Save next
-			if cn.prefix[len(cn.prefix)-1] == '/' {
-				nk = pkind
-				nn = cn
-				ns = search
-			}
-			cn = child
-			continue
+	if pi < len(pvalues) {
+		i := strings.IndexByte(search, '/')
+		if i < 0 {
+			i = len(search)
 		}
+		val := search[:i]
 
-		// Param node
-	Param:
-		if child = cn.findChildByKind(pkind); child != nil {
-			if len(pvalues) == n {
+		var any *node
+		for _, c := range n.children {
+			if c.kind != pkind {
 				continue
 			}
-
-			// Save next
-			if cn.prefix[len(cn.prefix)-1] == '/' { // Issue #623
-				nk = akind
-				nn = cn
-				ns = search
+			if c.constraint == nil {
+				any = c
+				continue
 			}
-
-			cn = child
-			i, l := 0, len(search)
-			for ; i < l && search[i] != '/'; i++ {
+			if c.constraint.match(val) {
+				pvalues[pi] = val
+				if m := c.match(search[i:], pvalues, pi+1); m != nil {
+					return m
+				}
 			}
-			pvalues[n] = search[:i]
-			n++
-			search = search[i:]
-			continue
 		}
-
-		// Any node
-	Any:
-		if cn = cn.findChildByKind(akind); cn == nil {
-			if nn != nil {
-				cn = nn
-				nn = cn.parent
-				search = ns
-				if nk == pkind {
-					goto Param
-				} else if nk == akind {
-					goto Any
-				}
+		if any != nil {
+			pvalues[pi] = val
+			if m := any.match(search[i:], pvalues, pi+1); m != nil {
+				return m
 			}
-			// Not found
-			return
 		}
-		pvalues[len(cn.pnames)-1] = search
-		break
+	}
+
+	if c := n.findChildByKind(akind); c != nil {
+		pvalues[len(c.pnames)-1] = search
+		return c
+	}
+	return nil
+}
+
+// Find 查找路由，找到路径对应的节点时返回true (包括405的情况)
+func (r *RadixTree) Find(method, path string, ctx *radixTreeCtx) bool {
+	ctx.path = path
+	ctx.handler = r.NotFoundHandler // ctx 来自pool，需要重置
+	ctx.route = nil
+	ctx.pnames = nil
+
+	pvalues := ctx.pvalues
+	cn := r.tree.match(path, pvalues, 0)
+	if cn == nil {
+		return false
 	}
 
 	ctx.handler = cn.findHandler(method)
+	ctx.route = cn.matchedRoute(method)
 	ctx.path = cn.ppath
 	ctx.pnames = cn.pnames
 
 	if ctx.handler == nil {
-		ctx.handler = cn.checkMethodNotAllowed()
+		ctx.handler = r.checkMethodNotAllowed(cn, method, path)
+		found := cn.methodHandler.allow != ""
 
 		if cn = cn.findChildByKind(akind); cn == nil {
-			return
+			return found
 		}
 		if h := cn.findHandler(method); h != nil {
 			ctx.handler = h
 		} else {
-			ctx.handler = cn.checkMethodNotAllowed()
+			ctx.handler = r.checkMethodNotAllowed(cn, method, path)
 		}
+		ctx.route = cn.matchedRoute(method)
 
 		ctx.path = cn.ppath
 		ctx.pnames = cn.pnames
 		pvalues[len(cn.pnames)-1] = ""
+		return found || cn.methodHandler.allow != ""
+	}
+
+	return true
+}
+
+// fixPath 路径未找到时，按照RadixTree的路径策略修正路径后再次查找
+func (r *RadixTree) fixPath(method, path string, req *http.Request, c *radixTreeCtx) bool {
+	redirect := false
+
+	if r.CleanPath != PathStrict {
+		if p := CleanPath(path); p != path {
+			path, redirect = p, r.CleanPath == PathRedirect
+			if r.Find(method, path, c) {
+				return r.fixed(method, path, req, c, redirect)
+			}
+		}
+	}
+
+	if r.TrailingSlash != PathStrict && path != "/" {
+		p := path + "/"
+		if path[len(path)-1] == '/' {
+			p = path[:len(path)-1]
+		}
+		if r.Find(method, p, c) {
+			return r.fixed(method, p, req, c, redirect || r.TrailingSlash == PathRedirect)
+		}
+	}
+
+	if r.CaseInsensitive != PathStrict {
+		if p, ok := r.tree.findCaseInsensitive(path, make([]byte, 0, len(path))); ok && r.Find(method, string(p), c) {
+			return r.fixed(method, string(p), req, c, redirect || r.CaseInsensitive == PathRedirect)
+		}
+	}
+
+	return false
+}
+
+// fixed 修正后的路径找到时，根据策略决定是否重定向
+func (r *RadixTree) fixed(method, path string, req *http.Request, c *radixTreeCtx, redirect bool) bool {
+	if !redirect {
+		return true
+	}
+
+	code := r.RedirectCode
+	if code == 0 {
+		code = http.StatusPermanentRedirect
+		if method == http.MethodGet || method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
 	}
 
-	return
+	path = localRedirectPath(path)
+	if req.URL.RawQuery != "" {
+		path = path + "?" + req.URL.RawQuery
+	}
+	c.handler = func(c Ctx) error {
+		return c.Redirect(code, path)
+	}
+	return true
+}
+
+// localRedirectPath 将开头连续的 / 和 \ 合并为一个 /
+// 避免 //evil.com 或者 /\evil.com 被浏览器当作其他站点的地址(开放重定向)
+func localRedirectPath(p string) string {
+	i := 0
+	for i < len(p) && (p[i] == '/' || p[i] == '\\') {
+		i++
+	}
+	return "/" + p[i:]
 }
 
 // Lookup Lookuper#Lookup
+// 注意: 不要在处理请求的同时向RadixTree注册路由，热更新请使用Twig#WithMuxer替换整个RadixTree
 func (r *RadixTree) Lookup(method, path string, req *http.Request) MuxerCtx {
 	c := r.pool.Get().(*radixTreeCtx)
-	r.Find(method, path, c)
+	if len(c.pvalues) < r.maxParam {
+		// ctx创建后又注册了参数更多的路由
+		c.pvalues = make([]string, r.maxParam)
+	}
+	if !r.Find(method, path, c) && !r.fixPath(method, path, req, c) {
+		c.path = path
+		c.handler = r.notFound(path)
+	}
 	c.handler = Merge(c.handler, r.m)
 	return c
 }
 
+// Routes Router#Routes 遍历路由树，返回全部已注册的路由
+func (r *RadixTree) Routes() []*Route {
+	var routes []*Route
+	r.tree.walk(func(n *node) {
+		routes = append(routes, n.methodHandler.routes()...)
+	})
+	sortRoutes(routes)
+	return routes
+}
+
+// Named NamedRouter#Named 根据名称查找路由，没有时返回nil
+func (r *RadixTree) Named(name string) *Route {
+	return r.names[name]
+}
+
+// setName 命名路由并更新索引，名称已经用于其他路径时panic
+func (r *RadixTree) setName(rt *Route, name string) {
+	if old, ok := r.names[name]; ok && old.Path != rt.Path {
+		panic(fmt.Sprintf("Twig: duplicate route name %s for %s, already used by %s", name, rt.Path, old.Path))
+	}
+	if old, ok := r.names[rt.Name]; ok && old == rt {
+		delete(r.names, rt.Name)
+	}
+	if r.names == nil {
+		r.names = make(map[string]*Route)
+	}
+	if _, ok := r.names[name]; !ok {
+		r.names[name] = rt
+	}
+	rt.Name = name
+}
+
 // Use Register#Use
 func (r *RadixTree) Use(m ...MiddlewareFunc) {
 	r.m = append(r.m, m...)
@@ -455,6 +945,11 @@ func (r *RadixTree) Use(m ...MiddlewareFunc) {
 
 // AddHandler Register#AddHandler
 func (r *RadixTree) AddHandler(method string, path string, h HandlerFunc, m ...MiddlewareFunc) {
+	r.AddRoute(method, path, h, m...)
+}
+
+// AddRoute RouteRegister#AddRoute
+func (r *RadixTree) AddRoute(method string, path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
 	handler := Merge(h, m)
-	r.Add(method, path, handler)
+	return r.Add(method, path, handler)
 }
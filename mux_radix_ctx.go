@@ -3,14 +3,15 @@ package twig
 import (
 	"bytes"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type radixTreeCtx struct {
@@ -21,6 +22,7 @@ type radixTreeCtx struct {
 	twig  *Twig
 
 	handler HandlerFunc
+	route   *Route
 	path    string
 
 	pnames  []string
@@ -35,7 +37,7 @@ func newRadixTreeCtx(tree *RadixTree) *radixTreeCtx {
 	c := &radixTreeCtx{
 		pvalues: make([]string, tree.maxParam),
 		tree:    tree,
-		handler: NotFoundHandler,
+		handler: tree.NotFoundHandler,
 		resp:    newResponseWrap(nil),
 	}
 
@@ -95,7 +97,7 @@ func (c *radixTreeCtx) FormValue(name string) string {
 
 func (c *radixTreeCtx) FormParams() (url.Values, error) {
 	if strings.HasPrefix(c.req.Header.Get(HeaderContentType), MIMEMultipartForm) {
-		if err := c.req.ParseMultipartForm(defaultMemory); err != nil {
+		if err := c.req.ParseMultipartForm(c.twig.MultipartMemory); err != nil {
 			return nil, err
 		}
 	} else {
@@ -107,12 +109,17 @@ func (c *radixTreeCtx) FormParams() (url.Values, error) {
 }
 
 func (c *radixTreeCtx) FormFile(name string) (*multipart.FileHeader, error) {
+	if c.req.MultipartForm == nil {
+		if _, err := c.MultipartForm(); err != nil {
+			return nil, err
+		}
+	}
 	_, fh, err := c.req.FormFile(name)
 	return fh, err
 }
 
 func (c *radixTreeCtx) MultipartForm() (*multipart.Form, error) {
-	err := c.req.ParseMultipartForm(defaultMemory)
+	err := c.req.ParseMultipartForm(c.twig.MultipartMemory)
 	return c.req.MultipartForm, err
 }
 
@@ -147,10 +154,7 @@ func (c *radixTreeCtx) Cookies() []*http.Cookie {
 }
 
 func (c *radixTreeCtx) JSON(code int, val interface{}) error {
-	enc := json.NewEncoder(c.resp)
-	WriteContentType(c.resp, MIMEApplicationJSONCharsetUTF8)
-	WriteHeaderCode(c.resp, code)
-	return enc.Encode(val)
+	return c.encode(code, codecOr(MIMEApplicationJSON, JSONCodec, c), val)
 }
 
 func (c *radixTreeCtx) JSONP(code int, callback string, val interface{}) (err error) {
@@ -182,11 +186,56 @@ func (c *radixTreeCtx) Blob(code int, contentType string, bs []byte) (err error)
 	return Byte(c.resp, code, contentType, bs)
 }
 
+// JSONWithETag 以JSON输出v并处理条件请求
+func (c *radixTreeCtx) JSONWithETag(code int, v interface{}) error {
+	return JSONWithETag(c, code, v)
+}
+
+// BlobWithETag 输出bs并处理条件请求
+func (c *radixTreeCtx) BlobWithETag(code int, contentType string, bs []byte) error {
+	return BlobWithETag(c, code, contentType, bs)
+}
+
+// CheckPreconditions 检查条件请求
+func (c *radixTreeCtx) CheckPreconditions(etag string, modtime time.Time) (bool, error) {
+	return CheckPreconditions(c, etag, modtime)
+}
+
 func (c *radixTreeCtx) XML(code int, v interface{}) (err error) {
-	enc := xml.NewEncoder(c.resp)
-	WriteContentType(c.resp, MIMEApplicationXMLCharsetUTF8)
-	WriteHeaderCode(c.resp, code)
-	return enc.Encode(v)
+	return c.encode(code, codecOr(MIMEApplicationXML, XMLCodec, c), v)
+}
+
+// Upgrade 将请求升级为WebSocket连接
+func (c *radixTreeCtx) Upgrade() (*WSConn, error) {
+	return Upgrade(c)
+}
+
+// SSE 开始输出Server-Sent Events
+func (c *radixTreeCtx) SSE() (*SSE, error) {
+	return NewSSE(c)
+}
+
+// HTML 使用Renderer插件输出模版name
+func (c *radixTreeCtx) HTML(code int, name string, data interface{}) error {
+	return HTML(c, code, name, data)
+}
+
+// Render 根据Accept选择Codec输出v，没有可以接受的媒体类型时使用默认的Codec
+func (c *radixTreeCtx) Render(code int, v interface{}) error {
+	mt := negotiate(c, v, nil)
+	if mt == "" {
+		mt = defaultMediaType(c)
+	}
+	return render(c, code, mt, v)
+}
+
+// Negotiate 根据Accept从offers中选择媒体类型输出v，没有可以接受的媒体类型时返回406错误
+func (c *radixTreeCtx) Negotiate(code int, v interface{}, offers ...string) error {
+	return Negotiate(c, code, v, offers...)
+}
+
+func (c *radixTreeCtx) encode(code int, codec Codec, v interface{}) error {
+	return encodeBlob(c, code, codec, v)
 }
 
 func (c *radixTreeCtx) Stream(code int, contentType string, r io.Reader) (err error) {
@@ -235,6 +284,16 @@ func (c *radixTreeCtx) Redirect(code int, url string) error {
 	return nil
 }
 
+// URL 根据路由名称生成URL
+func (c *radixTreeCtx) URL(name string, params ...interface{}) string {
+	return c.twig.URL(name, params...)
+}
+
+// Validate 校验i
+func (c *radixTreeCtx) Validate(i interface{}) error {
+	return Validate(i, c)
+}
+
 // Twig 获取当前Twig
 func (c *radixTreeCtx) Twig() *Twig {
 	return c.twig
@@ -245,6 +304,10 @@ func (c *radixTreeCtx) Logger() Logger {
 }
 
 func (c *radixTreeCtx) Error(e error) {
+	if c.tree.HttpErrorHandler != nil {
+		c.tree.HttpErrorHandler(e, c)
+		return
+	}
 	c.twig.HttpErrorHandler(e, c)
 }
 
@@ -264,6 +327,17 @@ func (c *radixTreeCtx) Path() string {
 	return c.path
 }
 
+func (c *radixTreeCtx) Route() *Route {
+	return c.route
+}
+
+func (c *radixTreeCtx) Meta(key string) interface{} {
+	if c.route == nil {
+		return nil
+	}
+	return c.route.Meta[key]
+}
+
 func (c *radixTreeCtx) Handler() HandlerFunc {
 	return c.handler
 }
@@ -278,3 +352,34 @@ func (c *radixTreeCtx) Param(name string) string {
 	}
 	return ""
 }
+
+func (c *radixTreeCtx) ParamInt(name string) (int, error) {
+	i, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		return 0, paramError(name, err)
+	}
+	return i, nil
+}
+
+func (c *radixTreeCtx) ParamInt64(name string) (int64, error) {
+	i, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		return 0, paramError(name, err)
+	}
+	return i, nil
+}
+
+func (c *radixTreeCtx) ParamUint64(name string) (uint64, error) {
+	i, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		return 0, paramError(name, err)
+	}
+	return i, nil
+}
+
+func (c *radixTreeCtx) HostParam(name string) string {
+	if params, ok := c.Get(hostParamsKey).(map[string]string); ok {
+		return params[name]
+	}
+	return ""
+}
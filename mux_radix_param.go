@@ -0,0 +1,103 @@
+package twig
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// ParamMatcher 路由参数约束，返回参数值是否满足约束
+type ParamMatcher func(string) bool
+
+var (
+	pmLock sync.RWMutex
+
+	// 内置的参数约束，在路由中使用 :id<int> 的方式声明
+	paramMatchers = map[string]ParamMatcher{
+		"int":   isInt,
+		"uint":  isUint,
+		"alpha": regexp.MustCompile(`^[A-Za-z]+$`).MatchString,
+		"alnum": regexp.MustCompile(`^[A-Za-z0-9]+$`).MatchString,
+		"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`).MatchString,
+	}
+)
+
+// RegisterParamMatcher 注册自定义的路由参数约束
+// 需要在注册路由之前调用
+func RegisterParamMatcher(name string, m ParamMatcher) {
+	pmLock.Lock()
+	defer pmLock.Unlock()
+	paramMatchers[name] = m
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+func isUint(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+// constraint 参数节点上的约束
+type constraint struct {
+	expr  string
+	match ParamMatcher
+}
+
+// newConstraint 根据表达式创建约束
+// 表达式为内置约束名称或者正则表达式，空表达式表示没有约束
+func newConstraint(expr string) *constraint {
+	if expr == "" {
+		return nil
+	}
+
+	pmLock.RLock()
+	m, ok := paramMatchers[expr]
+	pmLock.RUnlock()
+
+	if !ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("Twig: invalid param constraint <%s>, %v", expr, err))
+		}
+		m = re.MatchString
+	}
+
+	return &constraint{
+		expr:  expr,
+		match: m,
+	}
+}
+
+// scanParam 从路径的 ':' 位置i开始解析参数
+// 返回参数名称的结束位置, 约束表达式以及参数的结束位置
+// 约束使用 <> 包围，例如 :id<int>, :slug<[a-z-]+>
+func scanParam(path string, i int) (nameEnd int, expr string, end int) {
+	l := len(path)
+	for end = i + 1; end < l && path[end] != '/' && path[end] != '<'; end++ {
+	}
+	nameEnd = end
+
+	if end < l && path[end] == '<' {
+		depth := 0
+		for ; end < l; end++ {
+			if path[end] == '<' {
+				depth++
+			} else if path[end] == '>' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if depth != 0 {
+			panic("Twig: unclosed param constraint in " + path)
+		}
+		expr = path[nameEnd+1 : end]
+		end++
+	}
+	return
+}
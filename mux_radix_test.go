@@ -0,0 +1,91 @@
+package twig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRadixTreeRedirectOpenRedirect(t *testing.T) {
+	cases := []struct {
+		route, path, location string
+	}{
+		{"/:a/:b", "//evil.com/", "/evil.com"},
+		{"/:a", "/\\evil.com/", "/evil.com"},
+	}
+
+	for _, tc := range cases {
+		web := TODO()
+		tree := NewRadixTree()
+		tree.TrailingSlash = PathRedirect
+		web.WithMuxer(tree)
+		web.Config().Get(tc.route, func(c Ctx) error {
+			return c.NoContent()
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = tc.path
+		w := httptest.NewRecorder()
+		web.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMovedPermanently {
+			t.Fatalf("%s: code = %d, want %d", tc.path, w.Code, http.StatusMovedPermanently)
+		}
+		if loc := w.Header().Get(HeaderLocation); loc != tc.location {
+			t.Errorf("%s: Location = %q, want %q", tc.path, loc, tc.location)
+		}
+	}
+}
+
+func TestRadixTreeFind(t *testing.T) {
+	web := TODO()
+	r := web.Config()
+	for _, path := range []string{
+		"/users/new",
+		"/users/:id<int>/posts",
+		"/users/:name/profile",
+		"/users/:name",
+		"/files/:dir/meta",
+		"/files/*",
+		"/a/:x<int>/:y<int>/c",
+		"/a/:x/:y/d",
+	} {
+		path := path
+		r.Get(path, func(c Ctx) error {
+			s := path
+			for _, name := range c.Route().PNames {
+				s += " " + name + "=" + c.Param(name)
+			}
+			return c.String(http.StatusOK, s)
+		})
+	}
+
+	cases := []struct {
+		path, body string
+	}{
+		{"/users/new", "/users/new"},
+		{"/users/12/posts", "/users/:id<int>/posts id=12"},
+		{"/users/12/profile", "/users/:name/profile name=12"},
+		{"/users/bob/profile", "/users/:name/profile name=bob"},
+		{"/users/12", "/users/:name name=12"},
+		{"/users/newer", "/users/:name name=newer"},
+		{"/files/docs/meta", "/files/:dir/meta dir=docs"},
+		{"/files/docs/readme.md", "/files/* *=docs/readme.md"},
+		{"/a/1/2/c", "/a/:x<int>/:y<int>/c x=1 y=2"},
+		{"/a/1/2/d", "/a/:x/:y/d x=1 y=2"},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		w := httptest.NewRecorder()
+		web.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: code = %d, want %d", tc.path, w.Code, http.StatusOK)
+			continue
+		}
+		if body := w.Body.String(); body != tc.body {
+			t.Errorf("%s: body = %q, want %q", tc.path, body, tc.body)
+		}
+	}
+}
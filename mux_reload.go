@@ -0,0 +1,85 @@
+package twig
+
+import (
+	"context"
+	"fmt"
+)
+
+const routesReloaderID = "_twig_routes_reloader_"
+
+// RoutesReloader 路由热更新插件
+// Start和Reload时创建新的RadixTree，挂载Mounter后原子地替换Twig的默认Muxer
+// 正在处理的请求继续使用原来的路由表
+//
+// 新的RadixTree以第一次加载前的默认Muxer为基础，保留其中的设置、中间件(Config(web).Use)、
+// 按前缀设置的404/405处理和已经注册的路由，再挂载Mounter
+// 加载之后直接向默认Muxer注册的中间件和路由会在下一次Reload时丢失，需要放在Mounter中注册
+//
+//	web.UsePlugger(twig.NewRoutesReloader(mounter))
+//	twig.Signal(twig.Signals(map[os.Signal]twig.SignalFunc{
+//		syscall.SIGHUP: twig.Reload(web),
+//		os.Interrupt:   twig.Graceful(web, 15*time.Second),
+//	}), syscall.SIGHUP, os.Interrupt)
+type RoutesReloader struct {
+	mounter Mounter
+	twig    *Twig
+
+	base *RadixTree // 第一次加载前的默认Muxer
+	tree *RadixTree // 最近一次加载的RadixTree
+}
+
+// NewRoutesReloader 创建RoutesReloader，mounter负责注册需要热更新的路由
+// 见RoutesReloader，Start之后直接注册到默认Muxer的中间件和路由在Reload时不会保留
+func NewRoutesReloader(mounter Mounter) *RoutesReloader {
+	return &RoutesReloader{
+		mounter: mounter,
+	}
+}
+
+// ID Identifier#ID
+func (r *RoutesReloader) ID() string {
+	return routesReloaderID
+}
+
+// Attach Attacher#Attach
+func (r *RoutesReloader) Attach(t *Twig) {
+	r.twig = t
+}
+
+// Start Cycler#Start 加载路由
+func (r *RoutesReloader) Start() error {
+	return r.Reload()
+}
+
+// Shutdown Cycler#Shutdown
+func (r *RoutesReloader) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// Reload Reloader#Reload 重新构建路由表并替换默认Muxer
+// 构建失败(例如Strict模式下路由冲突)时保留原来的路由表
+func (r *RoutesReloader) Reload() (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("Twig: reload routes fatal, %v", e)
+		}
+	}()
+
+	// 默认Muxer不是最近一次加载的RadixTree时(第一次加载或者被WithMuxer替换)，以它为基础
+	if cur := r.twig.Muxer(); r.tree == nil || cur != Muxer(r.tree) {
+		r.base, _ = cur.(*RadixTree)
+	}
+
+	var tree *RadixTree
+	if r.base != nil {
+		tree = r.base.fork()
+	} else {
+		tree = NewRadixTree()
+	}
+	Attach(tree, r.twig)
+
+	Config(newTarget(tree, r.twig)).Mount(r.mounter)
+	r.twig.WithMuxer(tree)
+	r.tree = tree
+	return
+}
@@ -0,0 +1,153 @@
+package twig
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RendererID Ctx#Negotiate和Ctx#HTML使用的Renderer插件ID
+// 自定义的Renderer需要使用这个ID才能被Ctx使用
+const RendererID = "_twig_renderer_"
+
+// View 模版名称和数据
+// Ctx#Negotiate协商为text/html时使用Renderer输出，其他媒体类型使用Codec输出Data
+type View struct {
+	Name string
+	Data interface{}
+}
+
+// acceptRange Accept中的一项
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+// parseAccept 解析Accept，忽略q以外的参数
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, s := range strings.Split(accept, ",") {
+		params := strings.Split(s, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		if mt == "" {
+			continue
+		}
+		if mt == "*" {
+			mt = "*/*"
+		}
+		i := strings.IndexByte(mt, '/')
+		if i <= 0 || i == len(mt)-1 {
+			continue
+		}
+
+		r := acceptRange{typ: mt[:i], sub: mt[i+1:], q: 1}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if len(p) > 2 && (p[0] == 'q' || p[0] == 'Q') && p[1] == '=' {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil && q >= 0 && q <= 1 {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// match 返回offer匹配的q值和具体程度，不匹配时specificity为-1
+func (r acceptRange) match(typ, sub string) (q float64, specificity int) {
+	switch {
+	case r.typ == typ && r.sub == sub:
+		return r.q, 2
+	case r.typ == typ && r.sub == "*":
+		return r.q, 1
+	case r.typ == "*" && r.sub == "*":
+		return r.q, 0
+	}
+	return 0, -1
+}
+
+// NegotiateContentType 根据请求的Accept从offers中选择最合适的媒体类型
+// q值相同时选择匹配更具体的，再相同时按照offers的顺序
+// 没有Accept时返回第一个offer，没有可以接受的媒体类型时返回空字符串
+func NegotiateContentType(r *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accept := r.Header.Get(HeaderAccept)
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(accept)
+
+	best, bestQ, bestSpec := "", 0.0, -1
+	for _, offer := range offers {
+		mt := baseMediaType(offer)
+		i := strings.IndexByte(mt, '/')
+		if i < 0 {
+			continue
+		}
+		typ, sub := mt[:i], mt[i+1:]
+
+		// 使用最具体的匹配项的q值
+		q, spec := 0.0, -1
+		for _, r := range ranges {
+			if rq, rs := r.match(typ, sub); rs > spec {
+				q, spec = rq, rs
+			}
+		}
+		if spec < 0 || q == 0 {
+			continue
+		}
+		if q > bestQ || q == bestQ && spec > bestSpec {
+			best, bestQ, bestSpec = offer, q, spec
+		}
+	}
+	return best
+}
+
+// negotiate 在Codecs和Renderer中协商输出的媒体类型，offers为空时使用全部注册的媒体类型
+// 只有data为View并且注册了Renderer时提供text/html
+func negotiate(c Ctx, data interface{}, offers []string) string {
+	AddVary(c.Resp().Header(), HeaderAccept)
+
+	_, isView := data.(View)
+	if len(offers) == 0 {
+		if isView && GetRenderer(RendererID, c) != nil {
+			offers = append(offers, MIMETextHTML)
+		}
+		if cr := GetCodecRegistry(codecsID, c); cr != nil {
+			offers = append(offers, cr.MediaTypes()...)
+		}
+	}
+	return NegotiateContentType(c.Req(), offers...)
+}
+
+// Negotiate 根据Accept选择媒体类型输出data，没有可以接受的媒体类型时返回406错误
+func Negotiate(c Ctx, code int, data interface{}, offers ...string) error {
+	mt := negotiate(c, data, offers)
+	if mt == "" {
+		return ErrNotAcceptable
+	}
+	return render(c, code, mt, data)
+}
+
+// render 使用mt对应的Renderer或者Codec输出data
+func render(c Ctx, code int, mt string, data interface{}) error {
+	view, isView := data.(View)
+	if baseMediaType(mt) == MIMETextHTML && isView {
+		return c.HTML(code, view.Name, view.Data)
+	}
+
+	if isView {
+		data = view.Data
+	}
+	codec := GetCodec(mt, c)
+	if codec == nil && baseMediaType(mt) == MIMEApplicationJSON {
+		codec = JSONCodec
+	}
+	if codec == nil {
+		return ErrNotAcceptable
+	}
+	return encodeBlob(c, code, codec, data)
+}
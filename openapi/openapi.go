@@ -0,0 +1,343 @@
+// Package openapi 根据Twig中注册的路由生成OpenAPI 3文档
+//
+// 路由可以通过元数据提供额外的描述信息:
+//
+//	twig.Config(r).
+//		Post("/users", createUser).
+//		SetMeta(openapi.MetaSummary, "创建用户").
+//		SetMeta(openapi.MetaRequest, CreateUser{}).
+//		SetMeta(openapi.MetaResponse, User{})
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/twiglab/twig"
+)
+
+// OpenAPI 版本
+const Version = "3.0.3"
+
+// 路由元数据
+const (
+	MetaSummary     = "openapi.summary"     // string 摘要
+	MetaDescription = "openapi.description" // string 描述
+	MetaTags        = "openapi.tags"        // []string 标签
+	MetaRequest     = "openapi.request"     // 请求类型，按照Bind的规则反射
+	MetaResponse    = "openapi.response"    // 响应类型，以JSON输出
+	MetaIgnore      = "openapi.ignore"      // bool 不出现在文档中
+)
+
+// Document OpenAPI 文档
+type Document struct {
+	OpenAPI string               `json:"openapi"`
+	Info    Info                 `json:"info"`
+	Servers []*Server            `json:"servers,omitempty"`
+	Paths   map[string]*PathItem `json:"paths"`
+}
+
+// Info 文档的基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 同一路径下的操作
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty"`
+}
+
+// anyMethods Any注册的路由在文档中展开的方法
+var anyMethods = []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch}
+
+// has method对应的操作是否已经设置
+func (p *PathItem) has(method string) bool {
+	switch method {
+	case http.MethodGet:
+		return p.Get != nil
+	case http.MethodPut:
+		return p.Put != nil
+	case http.MethodPost:
+		return p.Post != nil
+	case http.MethodDelete:
+		return p.Delete != nil
+	case http.MethodOptions:
+		return p.Options != nil
+	case http.MethodHead:
+		return p.Head != nil
+	case http.MethodPatch:
+		return p.Patch != nil
+	case http.MethodTrace:
+		return p.Trace != nil
+	}
+	return false
+}
+
+// set 设置method对应的操作，OpenAPI不支持的方法返回false
+func (p *PathItem) set(method string, op *Operation) bool {
+	switch method {
+	case http.MethodGet:
+		p.Get = op
+	case http.MethodPut:
+		p.Put = op
+	case http.MethodPost:
+		p.Post = op
+	case http.MethodDelete:
+		p.Delete = op
+	case http.MethodOptions:
+		p.Options = op
+	case http.MethodHead:
+		p.Head = op
+	case http.MethodPatch:
+		p.Patch = op
+	case http.MethodTrace:
+		p.Trace = op
+	default:
+		return false
+	}
+	return true
+}
+
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Generate 根据路由生成OpenAPI文档
+// 同一路径和方法只使用第一个路由，例如不同Host的Muxer中注册了相同的路由，其余的路由被跳过并在返回的错误中列出
+// 使用Any注册的路由展开为路径上没有单独注册的GET, PUT, POST, DELETE和PATCH
+func Generate(info Info, routes []*twig.Route) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+
+	var anys []*twig.Route
+	var skipped []string
+	for _, r := range routes {
+		if ignore, _ := r.Meta[MetaIgnore].(bool); ignore {
+			continue
+		}
+		if r.Method == twig.ANY {
+			anys = append(anys, r) // 单独注册的方法优先
+			continue
+		}
+
+		path, params := formatPath(r)
+		item := doc.item(path)
+		if item.has(r.Method) {
+			skipped = append(skipped, r.Method+" "+r.Path)
+			continue
+		}
+		item.set(r.Method, newOperation(r, r.Method, params))
+	}
+
+	for _, r := range anys {
+		added := false
+		for _, method := range anyMethods {
+			path, params := formatPath(r) // 每个操作使用单独的参数列表
+			if item := doc.item(path); !item.has(method) {
+				item.set(method, newOperation(r, method, params))
+				added = true
+			}
+		}
+		if !added {
+			skipped = append(skipped, r.Method+" "+r.Path)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		if *item == (PathItem{}) {
+			delete(doc.Paths, path)
+		}
+	}
+
+	if len(skipped) > 0 {
+		return doc, fmt.Errorf("openapi: skipped conflicting routes: %s", strings.Join(skipped, ", "))
+	}
+	return doc, nil
+}
+
+// item 获取或者创建path对应的PathItem
+func (d *Document) item(path string) *PathItem {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	return item
+}
+
+// formatPath 将路由路径转换为OpenAPI的路径模版，并返回路径参数
+func formatPath(r *twig.Route) (string, []*Parameter) {
+	var params []*Parameter
+	path := r.Format(func(name, expr string) string {
+		if name == "*" {
+			name = "wildcard"
+		}
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   constraintSchema(expr),
+		})
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+// operationID 路由名称加上方法，保证Any或者同名的路由生成唯一的operationId
+func operationID(name, method string) string {
+	if name == "" {
+		return ""
+	}
+	return name + "_" + strings.ToLower(method)
+}
+
+func newOperation(r *twig.Route, method string, params []*Parameter) *Operation {
+	op := &Operation{
+		OperationID: operationID(r.Name, method),
+		Parameters:  params,
+		Responses: map[string]*Response{
+			strconv.Itoa(http.StatusOK): {Description: http.StatusText(http.StatusOK)},
+		},
+	}
+	op.Summary, _ = r.Meta[MetaSummary].(string)
+	op.Description, _ = r.Meta[MetaDescription].(string)
+	op.Tags, _ = r.Meta[MetaTags].([]string)
+
+	if req, ok := r.Meta[MetaRequest]; ok && req != nil {
+		// 与Bind相同，GET和DELETE以外的请求只使用有query标签的字段
+		if method == http.MethodGet || method == http.MethodDelete {
+			op.Parameters = append(op.Parameters, tagParams(req, "query", "query", false)...)
+		} else {
+			op.Parameters = append(op.Parameters, tagParams(req, "query", "query", true)...)
+			op.RequestBody = requestBody(req)
+		}
+		op.Parameters = append(op.Parameters, tagParams(req, "header", "header", true)...)
+	}
+
+	if resp, ok := r.Meta[MetaResponse]; ok && resp != nil {
+		op.Responses[strconv.Itoa(http.StatusOK)].Content = map[string]*MediaType{
+			twig.MIMEApplicationJSON: {Schema: SchemaOf(resp, "json")},
+		}
+	}
+
+	return op
+}
+
+// constraintSchema 根据路由参数约束生成Schema
+func constraintSchema(expr string) *Schema {
+	switch expr {
+	case "":
+		return &Schema{Type: "string"}
+	case "int":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "uint":
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &Schema{Type: "string", Pattern: "^[A-Za-z]+$"}
+	case "alnum":
+		return &Schema{Type: "string", Pattern: "^[A-Za-z0-9]+$"}
+	default:
+		return &Schema{Type: "string", Pattern: "^(?:" + expr + ")$"}
+	}
+}
+
+// JSON 以JSON格式输出文档
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML 以YAML格式输出文档
+func (d *Document) YAML() ([]byte, error) {
+	bs, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(bs)
+}
+
+// Handler 输出OpenAPI文档，format为 "json" 或者 "yaml"
+// 文档只包含处理当前请求的Muxer中的路由，不同Host的Muxer分别输出各自的文档
+// 文档在每次请求时生成，热更新路由后同样有效
+func Handler(info Info, format string) twig.HandlerFunc {
+	yaml := strings.EqualFold(format, "yaml")
+	return func(c twig.Ctx) error {
+		routes := c.Twig().Routes()
+		if rt := c.Route(); rt != nil {
+			if r, ok := rt.Muxer.(twig.Router); ok {
+				routes = r.Routes()
+			}
+		}
+		doc, err := Generate(info, routes)
+		if err != nil {
+			c.Logger().Println(err)
+		}
+		if !yaml {
+			return c.JSON(twig.OK, doc)
+		}
+		bs, err := doc.YAML()
+		if err != nil {
+			return err
+		}
+		return c.Blob(twig.OK, "application/yaml; charset=UTF-8", bs)
+	}
+}
+
+// Mounter 在path.json和path.yaml挂载OpenAPI文档，文档路由本身不会出现在文档中
+//
+//	web.Config().Mount(openapi.Mounter("/openapi", openapi.Info{Title: "api", Version: "1.0"}))
+func Mounter(path string, info Info) twig.Mounter {
+	return twig.MountFunc(func(a twig.Assembler) {
+		twig.Config(a).
+			Get(path+".json", Handler(info, "json")).SetMeta(MetaIgnore, true).
+			Get(path+".yaml", Handler(info, "yaml")).SetMeta(MetaIgnore, true)
+	})
+}
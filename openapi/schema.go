@@ -0,0 +1,193 @@
+package openapi
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/twiglab/twig"
+)
+
+// Schema OpenAPI Schema 的子集
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	bytesType           = reflect.TypeOf([]byte(nil))
+	bindUnmarshalerType = reflect.TypeOf((*twig.BindUnmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// SchemaOf 反射v的类型生成Schema
+// tag 为 "json" 时按照encoding/json的规则读取字段，否则按照Bind的规则读取 (例如 "form", "query")
+func SchemaOf(v interface{}, tag string) *Schema {
+	return typeSchema(reflect.TypeOf(v), tag, make(map[reflect.Type]bool))
+}
+
+func typeSchema(t reflect.Type, tag string, seen map[reflect.Type]bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	case isUnmarshaler(t):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: new(float64)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: typeSchema(t.Elem(), tag, seen)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), tag, seen)}
+	case reflect.Struct:
+		if seen[t] { // 递归类型
+			return &Schema{Type: "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, f := range fields(t, tag) {
+			s.Properties[f.name] = typeSchema(f.typ, tag, seen)
+		}
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+func isUnmarshaler(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return pt.Implements(bindUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+type field struct {
+	name   string
+	typ    reflect.Type
+	tagged bool // 字段使用了tag标签
+}
+
+// fields 列出结构体t中可以绑定的字段
+func fields(t reflect.Type, tag string) []field {
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous { // 未导出
+			continue
+		}
+
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		name := sf.Tag.Get(tag)
+		if tag == "json" {
+			name = strings.Split(name, ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+				fs = append(fs, fields(ft, tag)...)
+				continue
+			}
+		} else if name == "" && ft.Kind() == reflect.Struct && !isUnmarshaler(ft) && ft != timeType {
+			// 与Bind相同，没有tag的结构体字段展开
+			fs = append(fs, fields(ft, tag)...)
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+		f := field{name: name, typ: sf.Type, tagged: name != ""}
+		if name == "" {
+			f.name = sf.Name
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// hasTag 检查结构体t是否有字段使用了tag
+func hasTag(t reflect.Type, tag string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// tagParams 按照Bind读取tag的规则生成in位置的参数，explicit为true时只使用有tag标签的字段
+func tagParams(v interface{}, tag, in string, explicit bool) []*Parameter {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var ps []*Parameter
+	for _, f := range fields(t, tag) {
+		if explicit && !f.tagged {
+			continue
+		}
+		ps = append(ps, &Parameter{
+			Name:   f.name,
+			In:     in,
+			Schema: typeSchema(f.typ, tag, make(map[reflect.Type]bool)),
+		})
+	}
+	return ps
+}
+
+// requestBody 生成请求体，使用了form标签时同时支持表单
+func requestBody(v interface{}) *RequestBody {
+	body := &RequestBody{
+		Required: true,
+		Content: map[string]*MediaType{
+			twig.MIMEApplicationJSON: {Schema: SchemaOf(v, "json")},
+		},
+	}
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && hasTag(t, "form") {
+		body.Content[twig.MIMEApplicationForm] = &MediaType{Schema: SchemaOf(v, "form")}
+	}
+	return body
+}
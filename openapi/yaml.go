@@ -0,0 +1,95 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// jsonToYAML 将JSON转换为YAML
+// 只用于输出文档，字符串统一使用双引号，JSON的字符串转义在YAML中同样有效
+func jsonToYAML(bs []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	writeYAML(buf, v, 0, false)
+	return buf.Bytes(), nil
+}
+
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int, inline bool) {
+	pad := strings.Repeat("  ", indent)
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		if inline {
+			buf.WriteByte('\n')
+		}
+
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			buf.WriteString(pad)
+			buf.WriteString(yamlKey(k))
+			buf.WriteByte(':')
+			writeYAML(buf, val[k], indent+1, true)
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		if inline {
+			buf.WriteByte('\n')
+		}
+
+		for _, item := range val {
+			buf.WriteString(pad)
+			buf.WriteString("-")
+			if m, ok := item.(map[string]interface{}); ok && len(m) > 0 {
+				// 列表中的map，第一个key与 - 在同一行
+				sub := new(bytes.Buffer)
+				writeYAML(sub, m, indent+1, false)
+				buf.WriteByte(' ')
+				buf.Write(bytes.TrimLeft(sub.Bytes(), " "))
+				continue
+			}
+			writeYAML(buf, item, indent+1, true)
+		}
+	default:
+		buf.WriteByte(' ')
+		bs, _ := json.Marshal(val)
+		buf.Write(bs)
+		buf.WriteByte('\n')
+	}
+}
+
+// yamlKey 简单的key直接输出，否则使用双引号
+func yamlKey(k string) string {
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			bs, _ := json.Marshal(k)
+			return string(bs)
+		}
+	}
+	if k == "" || k[0] >= '0' && k[0] <= '9' || k[0] == '-' {
+		bs, _ := json.Marshal(k)
+		return string(bs)
+	}
+	return k
+}
@@ -35,12 +35,39 @@ func GetBinder(id string, c Ctx) Binder {
 	return GetPlugger(id, c).(Binder)
 }
 
+// Validator 数据校验接口
+// Validator 作为一个插件集成到Twig中,请实现Plugger接口
+type Validator interface {
+	Validate(interface{}) error
+}
+
+// GetValidator 获取校验接口，没有注册时返回nil
+func GetValidator(id string, c Ctx) Validator {
+	v, _ := GetPlugger(id, c).(Validator)
+	return v
+}
+
+// CodecRegistry 按照媒体类型获取Codec，见Codecs
+// CodecRegistry 作为一个插件集成到Twig中,请实现Plugger接口
+type CodecRegistry interface {
+	Codec(string) Codec
+	MediaTypes() []string
+}
+
+// GetCodecRegistry 获取Codec注册表，没有注册时返回nil
+func GetCodecRegistry(id string, c Ctx) CodecRegistry {
+	cr, _ := GetPlugger(id, c).(CodecRegistry)
+	return cr
+}
+
 type Renderer interface {
 	Render(io.Writer, string, interface{}, Ctx) error
 }
 
+// GetRenderer 获取模版接口，没有注册时返回nil
 func GetRenderer(id string, c Ctx) Renderer {
-	return GetPlugger(id, c).(Renderer)
+	r, _ := GetPlugger(id, c).(Renderer)
+	return r
 }
 
 // IdGenerator ID发生器接口
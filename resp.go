@@ -2,6 +2,7 @@ package twig
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"net"
 	"net/http"
@@ -22,8 +23,17 @@ func (r *ResponseWrap) Header() http.Header {
 	return r.Writer.Header()
 }
 
+// Flush 输出缓冲的数据，Writer不支持Flush时不做处理，见Flushable
 func (r *ResponseWrap) Flush() {
-	r.Writer.(http.Flusher).Flush()
+	if f, ok := r.Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Flushable Writer是否支持Flush
+func (r *ResponseWrap) Flushable() bool {
+	_, ok := r.Writer.(http.Flusher)
+	return ok
 }
 
 // 设置header时查是否已经输出内容
@@ -62,9 +72,28 @@ func (r *ResponseWrap) WriteString(str string) (n int, err error) {
 	return
 }
 
-// Hijack Hijack 支持
+// Hijack Hijack 支持，Writer不支持时返回错误
 func (r *ResponseWrap) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return r.Writer.(http.Hijacker).Hijack()
+	hj, ok := r.Writer.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("twig: response does not implement http.Hijacker")
+	}
+	return hj.Hijack()
+}
+
+// discardWriter 丢弃响应体
+type discardWriter struct {
+	http.ResponseWriter
+}
+
+func (w discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w discardWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
 func (r *ResponseWrap) reset(w http.ResponseWriter) {
@@ -0,0 +1,138 @@
+package twig
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Route 描述一个已注册的路由
+type Route struct {
+	Name   string   // 路由名称，用于反向生成URL
+	Method string   // 请求方法
+	Path   string   // 注册时的原始路径
+	PNames []string // 路径参数名称
+	Muxer  Muxer    // 路由所属的Muxer
+	Meta   M        // 路由元数据，例如权限，限流分类，缓存策略
+
+	handler HandlerFunc
+}
+
+// SetMeta 设置路由元数据
+func (r *Route) SetMeta(key string, val interface{}) *Route {
+	if r.Meta == nil {
+		r.Meta = make(M)
+	}
+	r.Meta[key] = val
+	return r
+}
+
+// Format 使用f的返回值依次替换路径中的 :param(包括约束) 和 *
+// f的参数为参数名称和约束表达式，* 的名称为 "*"
+func (r *Route) Format(f func(name, expr string) string) string {
+	var sb strings.Builder
+	path := r.Path
+
+	for i, l := 0, len(path); i < l; i++ {
+		switch path[i] {
+		case ':':
+			nameEnd, expr, end := scanParam(path, i)
+			sb.WriteString(f(path[i+1:nameEnd], expr))
+			i = end - 1
+		case '*':
+			sb.WriteString(f("*", ""))
+		default:
+			sb.WriteByte(path[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// URL 使用params依次替换路径中的 :param(包括约束) 和 * ，生成URL
+// :param 的值整体转义，* 的值按 / 分段转义，params不足时，剩余的参数保持原样
+func (r *Route) URL(params ...interface{}) string {
+	n := 0
+	return r.Format(func(name, expr string) string {
+		if n >= len(params) {
+			if name == "*" {
+				return name
+			}
+			if expr != "" {
+				return ":" + name + "<" + expr + ">"
+			}
+			return ":" + name
+		}
+		n++
+		v := fmt.Sprint(params[n-1])
+		if name != "*" {
+			return url.PathEscape(v)
+		}
+		segs := strings.Split(v, "/")
+		for i, seg := range segs {
+			segs[i] = url.PathEscape(seg)
+		}
+		return strings.Join(segs, "/")
+	})
+}
+
+// Router 可以列出全部已注册路由的Muxer
+type Router interface {
+	Routes() []*Route
+}
+
+// NamedRouter 可以根据名称查找路由的Muxer，见Conf#SetName
+type NamedRouter interface {
+	Named(string) *Route
+}
+
+// routeNamer 维护路由名称索引的Muxer
+type routeNamer interface {
+	setName(*Route, string)
+}
+
+// methodIndex 返回method在methods中的位置，未知的method排在最后
+func methodIndex(method string) int {
+	for i, m := range methods {
+		if m == method {
+			return i
+		}
+	}
+	return len(methods)
+}
+
+// sortRoutes 按照路径和请求方法排序，保证输出稳定
+func sortRoutes(routes []*Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		mi, mj := methodIndex(routes[i].Method), methodIndex(routes[j].Method)
+		if mi != mj {
+			return mi < mj
+		}
+		return routes[i].Method < routes[j].Method
+	})
+}
+
+// namedRoute 在mux中根据名称查找路由，没有实现NamedRouter时遍历全部路由
+func namedRoute(mux Muxer, name string) *Route {
+	switch r := mux.(type) {
+	case NamedRouter:
+		return r.Named(name)
+	case Router:
+		return findRoute(r.Routes(), name)
+	}
+	return nil
+}
+
+// findRoute 根据名称查找路由
+func findRoute(routes []*Route, name string) *Route {
+	for _, r := range routes {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
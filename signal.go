@@ -42,6 +42,27 @@ func Graceful(t *Twig, timeout time.Duration) SignalFunc {
 	}
 }
 
+// Reload 重新加载Twig，例如在SIGHUP时热更新路由
+// 返回false，继续等待下一个信号
+func Reload(t *Twig) SignalFunc {
+	return func(sig os.Signal) bool {
+		if err := t.Reload(); err != nil {
+			t.Logger.Println(err)
+		}
+		return false
+	}
+}
+
+// Signals 按照信号分发给不同的SignalFunc，未配置的信号被忽略
+func Signals(fs map[os.Signal]SignalFunc) SignalFunc {
+	return func(sig os.Signal) bool {
+		if f, ok := fs[sig]; ok {
+			return f(sig)
+		}
+		return false
+	}
+}
+
 // Signal 用于监听系统信号并堵塞当前gorouting
 // 参数f为信号处理函数
 // 参数sig 为需要监听的系统信号，未出现在sig中的信号会被忽略
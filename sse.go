@@ -0,0 +1,300 @@
+package twig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamingUnsupported ResponseWriter不支持Flush
+var ErrStreamingUnsupported = errors.New("streaming unsupported")
+
+// Event Server-Sent Events事件
+type Event struct {
+	ID    string
+	Event string
+	Retry time.Duration
+	// Data string和[]byte原样输出，多行时拆分为多个data字段，其他类型使用JSON输出
+	Data interface{}
+}
+
+// encode 按照text/event-stream格式输出事件
+func (e *Event) encode(buf *bytes.Buffer) error {
+	if e.ID != "" {
+		buf.WriteString("id: " + sseField(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + sseField(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(e.Retry/time.Millisecond), 10) + "\n")
+	}
+
+	var data string
+	switch d := e.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		bs, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(bs)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// sseField 去掉字段中的换行
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// SSE Server-Sent Events输出流，可以在多个goroutine中使用
+//
+//	sse, err := c.SSE()
+//	if err != nil {
+//		return err
+//	}
+//	return sse.Stream(events, 15*time.Second)
+type SSE struct {
+	c    Ctx
+	lock sync.Mutex
+}
+
+// NewSSE 输出text/event-stream响应头，ResponseWriter不支持Flush时返回ErrStreamingUnsupported
+func NewSSE(c Ctx) (*SSE, error) {
+	resp := c.Resp()
+	if !resp.Flushable() {
+		return nil, ErrStreamingUnsupported
+	}
+
+	h := resp.Header()
+	h.Set(HeaderContentType, MIMETextEventStream)
+	h.Set(HeaderCacheControl, "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // 关闭nginx的缓冲
+	resp.WriteHeader(http.StatusOK)
+	resp.Flush()
+
+	return &SSE{c: c}, nil
+}
+
+// LastEventID 客户端重连时发送的最后一个事件ID
+func (s *SSE) LastEventID() string {
+	req := s.c.Req()
+	if id := req.Header.Get(HeaderLastEventID); id != "" {
+		return id
+	}
+	return req.URL.Query().Get("lastEventId") // EventSource polyfill
+}
+
+// Done 客户端断开连接时关闭
+func (s *SSE) Done() <-chan struct{} {
+	return s.c.Req().Context().Done()
+}
+
+// Send 发送事件，客户端断开连接后返回错误
+func (s *SSE) Send(e *Event) error {
+	buf := new(bytes.Buffer)
+	if err := e.encode(buf); err != nil {
+		return err
+	}
+	return s.write(buf.Bytes())
+}
+
+// Comment 发送注释，通常用作心跳，避免连接被代理断开
+func (s *SSE) Comment(text string) error {
+	return s.write([]byte(": " + sseField(text) + "\n\n"))
+}
+
+func (s *SSE) write(bs []byte) error {
+	if err := s.c.Req().Context().Err(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	resp := s.c.Resp()
+	if _, err := resp.Write(bs); err != nil {
+		return err
+	}
+	resp.Flush()
+	return nil
+}
+
+// Stream 发送events中的事件，heartbeat大于0时定时发送心跳
+// 客户端断开连接或者events关闭时返回nil
+func (s *SSE) Stream(events <-chan *Event, heartbeat time.Duration) error {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.Send(e); err != nil {
+				return s.streamErr(err)
+			}
+		case <-tick:
+			if err := s.Comment("ping"); err != nil {
+				return s.streamErr(err)
+			}
+		}
+	}
+}
+
+// streamErr 客户端断开连接导致的错误不需要处理
+func (s *SSE) streamErr(err error) error {
+	if s.c.Req().Context().Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// Broadcaster 将事件发送给全部订阅者
+// 保留最近的history个事件，订阅时根据Last-Event-ID补发错过的事件
+// 订阅者的缓冲区满时断开该订阅者，客户端重连后补发事件
+//
+//	b := twig.NewBroadcaster(16, 100)
+//	web.Config().Get("/events", b.Handler(15*time.Second))
+//	b.Publish(&twig.Event{Event: "progress", Data: p})
+type Broadcaster struct {
+	lock    sync.RWMutex
+	subs    map[chan *Event]struct{}
+	buffer  int
+	history []*Event
+	size    int
+	seq     uint64
+	closed  bool
+}
+
+// NewBroadcaster 创建Broadcaster，buffer为每个订阅者的缓冲区大小，history为保留的事件数量
+func NewBroadcaster(buffer, history int) *Broadcaster {
+	return &Broadcaster{
+		subs:   make(map[chan *Event]struct{}),
+		buffer: buffer,
+		size:   history,
+	}
+}
+
+// Subscribe 订阅事件，lastEventID不为空时先补发之后的事件
+// 使用完毕后调用cancel取消订阅
+func (b *Broadcaster) Subscribe(lastEventID string) (events <-chan *Event, cancel func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var missed []*Event
+	if lastEventID != "" {
+		for i, e := range b.history {
+			if e.ID == lastEventID {
+				missed = b.history[i+1:]
+				break
+			}
+		}
+	}
+
+	ch := make(chan *Event, b.buffer+len(missed))
+	for _, e := range missed {
+		ch <- e
+	}
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+
+	return ch, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		b.remove(ch)
+	}
+}
+
+// Publish 发送事件，ID为空时自动生成递增的ID
+func (b *Broadcaster) Publish(e *Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(b.seq, 10)
+	}
+	if b.size > 0 {
+		if len(b.history) >= b.size {
+			n := copy(b.history, b.history[len(b.history)-b.size+1:])
+			b.history = b.history[:n]
+		}
+		b.history = append(b.history, e)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.remove(ch) // 缓冲区满，断开慢的订阅者
+		}
+	}
+}
+
+// Len 当前的订阅者数量
+func (b *Broadcaster) Len() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return len(b.subs)
+}
+
+// Close 关闭全部订阅
+func (b *Broadcaster) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.closed = true
+	for ch := range b.subs {
+		b.remove(ch)
+	}
+}
+
+func (b *Broadcaster) remove(ch chan *Event) {
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Handler 返回订阅事件的HandlerFunc，heartbeat为心跳间隔
+func (b *Broadcaster) Handler(heartbeat time.Duration) HandlerFunc {
+	return func(c Ctx) error {
+		sse, err := c.SSE()
+		if err != nil {
+			return err
+		}
+		events, cancel := b.Subscribe(sse.LastEventID())
+		defer cancel()
+		return sse.Stream(events, heartbeat)
+	}
+}
@@ -0,0 +1,171 @@
+package twig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// TemplateRenderer 基于html/template的Renderer插件
+// 每个页面模版和全部布局、公共模版一起解析，页面之间定义的同名模版互不影响
+// 模版名称为文件在fs中的路径，例如 pages/index.html
+//
+//	r := twig.NewTemplateRenderer(os.DirFS("views"), "pages/*.html", "layouts/*.html", "partials/*.html")
+//	r.Layout = "layouts/base.html" // 布局中使用 {{template "content" .}} 引用页面中定义的content
+//	web.UsePlugger(r)
+//	...
+//	c.HTML(http.StatusOK, "pages/index.html", data)
+//
+// Twig#Debug为true时每次输出前都会重新加载模版，也可以通过Twig#Reload重新加载
+type TemplateRenderer struct {
+	// Layout 执行的布局模版名称，为空时直接执行页面模版
+	Layout string
+	// Funcs 模版函数，需要在加载模版之前设置
+	Funcs template.FuncMap
+
+	fsys    fs.FS
+	pages   string
+	layouts []string
+
+	lock      sync.RWMutex
+	templates map[string]*template.Template
+
+	twig *Twig
+}
+
+// NewTemplateRenderer 创建TemplateRenderer
+// pages为页面模版的glob，layouts为布局和公共模版的glob
+func NewTemplateRenderer(fsys fs.FS, pages string, layouts ...string) *TemplateRenderer {
+	return &TemplateRenderer{
+		fsys:    fsys,
+		pages:   pages,
+		layouts: layouts,
+	}
+}
+
+// NewTemplateRendererDir 使用目录dir创建TemplateRenderer
+func NewTemplateRendererDir(dir string, pages string, layouts ...string) *TemplateRenderer {
+	return NewTemplateRenderer(os.DirFS(dir), pages, layouts...)
+}
+
+// ID Identifier#ID
+func (r *TemplateRenderer) ID() string {
+	return RendererID
+}
+
+// Attach Attacher#Attach
+func (r *TemplateRenderer) Attach(t *Twig) {
+	r.twig = t
+}
+
+// Start Cycler#Start 加载模版
+func (r *TemplateRenderer) Start() error {
+	return r.Load()
+}
+
+// Shutdown Cycler#Shutdown
+func (r *TemplateRenderer) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// Reload Reloader#Reload 重新加载模版
+func (r *TemplateRenderer) Reload() error {
+	return r.Load()
+}
+
+// Load 加载全部模版，失败时保留原来的模版
+func (r *TemplateRenderer) Load() error {
+	base := template.New("").Funcs(r.Funcs)
+	for _, pattern := range r.layouts {
+		if err := r.parseGlob(base, pattern); err != nil {
+			return err
+		}
+	}
+
+	pages, err := fs.Glob(r.fsys, r.pages)
+	if err != nil {
+		return err
+	}
+
+	templates := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err = r.parseFile(t, page); err != nil {
+			return err
+		}
+		templates[page] = t
+	}
+
+	r.lock.Lock()
+	r.templates = templates
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *TemplateRenderer) parseGlob(t *template.Template, pattern string) error {
+	files, err := fs.Glob(r.fsys, pattern)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = r.parseFile(t, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *TemplateRenderer) parseFile(t *template.Template, file string) error {
+	bs, err := fs.ReadFile(r.fsys, file)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(file).Parse(string(bs))
+	return err
+}
+
+// Render Renderer#Render 使用页面模版name输出data
+func (r *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c Ctx) error {
+	r.lock.RLock()
+	loaded := r.templates != nil
+	r.lock.RUnlock()
+
+	if !loaded || r.twig != nil && r.twig.Debug {
+		if err := r.Load(); err != nil {
+			return err
+		}
+	}
+
+	r.lock.RLock()
+	t, ok := r.templates[name]
+	r.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("twig: template %s not found", name)
+	}
+
+	if r.Layout != "" && t.Lookup(r.Layout) != nil {
+		return t.ExecuteTemplate(w, r.Layout, data)
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// HTML 使用Renderer插件输出模版name
+func HTML(c Ctx, code int, name string, data interface{}) error {
+	r := GetRenderer(RendererID, c)
+	if r == nil {
+		return ErrRendererNotRegistered
+	}
+	buf := new(bytes.Buffer)
+	if err := r.Render(buf, name, data, c); err != nil {
+		return err
+	}
+	return c.Blob(code, MIMETextHTMLCharsetUTF8, buf.Bytes())
+}
@@ -2,6 +2,7 @@ package twig
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 )
@@ -43,6 +44,9 @@ type Twig struct {
 
 	Debug bool
 
+	// MultipartMemory 解析multipart表单时使用的最大内存，超过的部分保存在临时文件中
+	MultipartMemory int64
+
 	pre []MiddlewareFunc
 	mid []MiddlewareFunc
 
@@ -58,6 +62,8 @@ func TODO() *Twig {
 	t := &Twig{
 		Debug: false,
 
+		MultipartMemory: defaultMemory,
+
 		name: "main",
 		typ:  "Twig",
 
@@ -71,7 +77,7 @@ func TODO() *Twig {
 	*/
 	idGen := uuidGen{}
 	t.id = idGen.NextID()
-	t.UsePlugger(idGen, &defaultBinder{})
+	t.UsePlugger(idGen, &defaultBinder{}, NewStructValidator(), NewCodecs())
 
 	/*
 		设置默认的Twig组建
@@ -82,10 +88,9 @@ func TODO() *Twig {
 		twig: t,
 	}
 
-	t.muxes = &muxes{
-		def:  NewRadixTree(),
-		twig: t,
-	}
+	def := NewRadixTree()
+	Attach(def, t)
+	t.muxes = newMuxes(def, t)
 
 	return t
 }
@@ -94,12 +99,32 @@ func (t *Twig) WithLogger(l Logger) {
 	t.Logger = l
 }
 
+// WithMuxer 替换默认的Muxer，例如使用设置了路径策略的RadixTree
+// 替换是原子的，运行时调用可以用于热更新路由，正在处理的请求不受影响
+func (t *Twig) WithMuxer(m Muxer) {
+	Attach(m, t)
+	t.muxes.SetDefault(m)
+}
+
+// ReplaceMuxer 原子地使用mux替换old，old为默认Muxer或者通过AddMuxer增加的Muxer
+// old不存在时返回false
+func (t *Twig) ReplaceMuxer(old, mux Muxer) bool {
+	Attach(mux, t)
+	return t.muxes.Replace(old, mux)
+}
+
+// Muxer 返回当前的默认Muxer
+func (t *Twig) Muxer() Muxer {
+	return t.muxes.Default()
+}
+
 func (t *Twig) AddServer(s ...Server) {
 	t.lead.AddServer(s...)
 }
 
 // AddMuxer 增加Muxer， match 决定这个muxer在何种情况使用
 func (t *Twig) AddMuxer(mux Muxer, match Matcher) Assembler {
+	Attach(mux, t)
 	t.muxes.AddMuxer(mux, match)
 	return &target{
 		PluginHelper: t,
@@ -111,6 +136,19 @@ func (t *Twig) AddMuxerMatcherFunc(mux Muxer, match MatcherFunc) Assembler {
 	return t.AddMuxer(mux, match)
 }
 
+// Routes 返回全部Muxer中已注册的路由，用于打印或者比对路由表
+func (t *Twig) Routes() []*Route {
+	return t.muxes.Routes()
+}
+
+// URL 根据路由名称反向生成URL，路由不存在时返回空字符串
+func (t *Twig) URL(name string, params ...interface{}) string {
+	if r := t.muxes.Named(name); r != nil {
+		return r.URL(params...)
+	}
+	return ""
+}
+
 // Pre 中间件支持， 注意Pre中间件工作在路由之前
 func (t *Twig) Pre(m ...MiddlewareFunc) {
 	t.pre = append(t.pre, m...)
@@ -137,10 +175,18 @@ func (t *Twig) GetPlugger(id string) Plugger {
 // ServeHTTP 实现`http.Handler`接口
 func (t *Twig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	method, path := r.Method, GetReqPath(r)
-	c := t.muxes.Lookup(method, path, r)
+	mux, match := t.muxes.match(r)
+	c := mux.Lookup(method, path, r)
 
 	c.Reset(w, r, t)
 
+	// Host等Matcher提取的参数
+	if capturer, ok := match.(Capturer); ok {
+		if params := capturer.Capture(r); params != nil {
+			c.Set(hostParamsKey, params)
+		}
+	}
+
 	/*
 		h := Merge(func(ctx Ctx) error { //闭包，处理Twig级中间件，结束后处理Pre中间件
 			handler := Merge(c.Handler(), t.mid) // 处理Twig级中间件
@@ -168,10 +214,15 @@ func (t *Twig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	// ------------------------------------------------------------
 
+	// HEAD请求在全部中间件之外丢弃响应体，中间件(例如ETag)可以看到和GET相同的响应
+	if r.Method == http.MethodHead {
+		h = DiscardBody(h)
+	}
+
 	if err := h(c); err != nil {
-		// 链式调用，如果出错，交给Twig的HttpErrorHandler处理
-		// 默认情况下由全局ErrorHandler处理， 后续有各自的路由处理ErrorHandler
-		t.HttpErrorHandler(err, c)
+		// 链式调用，如果出错，交给Ctx处理
+		// Muxer设置了HttpErrorHandler时由Muxer处理，否则由Twig的HttpErrorHandler处理
+		c.Error(err)
 	}
 
 	// 释放Ctx， Ctx的释放由创建者完成
@@ -191,6 +242,23 @@ func (t *Twig) Start() error {
 	return t.lead.Start()
 }
 
+// Reload Reloader#Reload 重新加载实现了Reloader的插件，例如RoutesReloader
+// 某个插件加载失败时继续加载其他插件，返回第一个错误
+func (t *Twig) Reload() (err error) {
+	t.Logger.Printf("Twig@%s(id = %s) reload\n", t.Name(), t.ID())
+	for _, p := range t.plugins {
+		if reloader, ok := p.(Reloader); ok {
+			if e := reloader.Reload(); e != nil {
+				t.Logger.Printf("Plugin (id = %s) reload fatal, Err = %v\n", p.ID(), e)
+				if err == nil {
+					err = fmt.Errorf("Twig: plugin %s reload fatal, %v", p.ID(), e)
+				}
+			}
+		}
+	}
+	return
+}
+
 // Start Cycler#Shutdown
 func (t *Twig) Shutdown(ctx context.Context) error {
 	for _, p := range t.plugins {
@@ -233,7 +301,7 @@ func (t *Twig) Config() *Conf {
 	return Config(
 		&target{
 			PluginHelper: t,
-			Register:     t.muxes.def,
+			Register:     t.muxes.Default(),
 		},
 	)
 }
@@ -6,11 +6,48 @@ type Assembler interface {
 	PluginHelper
 }
 
+// target 默认的Assembler
+// Register实现了ErrorRegister或者PrefixErrorRegister时，可以设置错误处理，否则设置错误处理不做任何处理
+// Register实现了RouteRegister时，可以返回注册的路由
 type target struct {
 	Register
 	PluginHelper
 }
 
+func (t *target) AddRoute(method, path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return addRoute(t.Register, method, path, h, m...)
+}
+
+func (t *target) SetHttpErrorHandler(h HttpErrorHandler) {
+	if er, ok := t.Register.(ErrorRegister); ok {
+		er.SetHttpErrorHandler(h)
+	}
+}
+
+func (t *target) SetNotFoundHandler(h HandlerFunc) {
+	if er, ok := t.Register.(ErrorRegister); ok {
+		er.SetNotFoundHandler(h)
+	}
+}
+
+func (t *target) SetMethodNotAllowedHandler(h HandlerFunc) {
+	if er, ok := t.Register.(ErrorRegister); ok {
+		er.SetMethodNotAllowedHandler(h)
+	}
+}
+
+func (t *target) SetPrefixNotFoundHandler(prefix string, h HandlerFunc) {
+	if per, ok := t.Register.(PrefixErrorRegister); ok {
+		per.SetPrefixNotFoundHandler(prefix, h)
+	}
+}
+
+func (t *target) SetPrefixMethodNotAllowedHandler(prefix string, h HandlerFunc) {
+	if per, ok := t.Register.(PrefixErrorRegister); ok {
+		per.SetPrefixMethodNotAllowedHandler(prefix, h)
+	}
+}
+
 func newTarget(r Register, twig *Twig) Assembler {
 	return &target{
 		Register:     r,
@@ -32,6 +69,7 @@ func (m MountFunc) Mount(target Assembler) {
 // Conf Twig路由配置工具
 type Conf struct {
 	target Assembler
+	route  *Route // 最近一次注册的路由
 }
 
 func config(r Register, twig *Twig) *Conf {
@@ -59,13 +97,44 @@ func Config(r Register) *Conf {
 
 // Use 当前Register增加中间件
 func (c *Conf) Use(m ...MiddlewareFunc) *Conf {
-	c.Use(m...)
+	c.target.Use(m...)
 	return c
 }
 
 // AddHandler 增加Handler
 func (c *Conf) AddHandler(method, path string, handler HandlerFunc, m ...MiddlewareFunc) *Conf {
-	c.target.AddHandler(method, path, handler, m...)
+	c.route = addRoute(c.target, method, path, handler, m...)
+	return c
+}
+
+// SetName 为最近一次注册的路由命名，用于Twig#URL反向生成URL
+// 同一个Muxer中名称已经用于其他路径时panic，不同方法的相同路径可以使用同一个名称
+// Register没有实现RouteRegister时无法取得路由，同样panic
+//
+//	twig.Config(r).
+//		Get("/users/:id", handler).SetName("user")
+func (c *Conf) SetName(name string) *Conf {
+	r := c.route
+	if r == nil {
+		panic("Twig: no route to name")
+	}
+	if n, ok := r.Muxer.(routeNamer); ok {
+		n.setName(r, name)
+	} else {
+		r.Name = name
+	}
+	return c
+}
+
+// SetMeta 为最近一次注册的路由设置元数据，中间件可以通过Ctx#Meta读取
+//
+//	twig.Config(r).
+//		Get("/admin", handler).SetMeta("scope", "admin")
+func (c *Conf) SetMeta(key string, val interface{}) *Conf {
+	if c.route == nil {
+		panic("Twig: no route to set meta")
+	}
+	c.route.SetMeta(key, val)
 	return c
 }
 
@@ -101,6 +170,39 @@ func (c *Conf) Trace(path string, handler HandlerFunc, m ...MiddlewareFunc) *Con
 	return c.AddHandler(TRACE, path, handler, m...)
 }
 
+// Any 注册处理全部方法的handler，包括WebDAV和自定义方法
+// 单独注册的方法优先，GET存在时HEAD使用GET，OPTIONS仍然自动处理
+func (c *Conf) Any(path string, handler HandlerFunc, m ...MiddlewareFunc) *Conf {
+	return c.AddHandler(ANY, path, handler, m...)
+}
+
+// SetHttpErrorHandler 设置当前Muxer的错误处理
+// Assembler没有实现ErrorRegister时不做任何处理，Group中设置的是整个Muxer的错误处理
+func (c *Conf) SetHttpErrorHandler(h HttpErrorHandler) *Conf {
+	if er, ok := c.target.(ErrorRegister); ok {
+		er.SetHttpErrorHandler(h)
+	}
+	return c
+}
+
+// SetNotFoundHandler 设置404处理，在Group中只对Group的路径生效
+// Assembler没有实现ErrorRegister时不做任何处理
+func (c *Conf) SetNotFoundHandler(h HandlerFunc) *Conf {
+	if er, ok := c.target.(ErrorRegister); ok {
+		er.SetNotFoundHandler(h)
+	}
+	return c
+}
+
+// SetMethodNotAllowedHandler 设置405处理，在Group中只对Group的路径生效
+// Assembler没有实现ErrorRegister时不做任何处理
+func (c *Conf) SetMethodNotAllowedHandler(h HandlerFunc) *Conf {
+	if er, ok := c.target.(ErrorRegister); ok {
+		er.SetMethodNotAllowedHandler(h)
+	}
+	return c
+}
+
 // Mount 挂载Mounter到当前Assembler
 func (c *Conf) Mount(mount Mounter) *Conf {
 	mount.Mount(c.target)
@@ -150,6 +252,48 @@ func (g *group) Use(mid ...MiddlewareFunc) {
 }
 
 func (g *group) AddHandler(method, path string, h HandlerFunc, m ...MiddlewareFunc) {
+	g.AddRoute(method, path, h, m...)
+}
+
+func (g *group) AddRoute(method, path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
 	handler := Merge(h, g.m)
-	g.Assembler.AddHandler(method, g.prefix+path, handler, m...)
+	return addRoute(g.Assembler, method, g.prefix+path, handler, m...)
+}
+
+// SetNotFoundHandler 设置Group路径下的404处理，Group的中间件同样生效
+func (g *group) SetNotFoundHandler(h HandlerFunc) {
+	g.SetPrefixNotFoundHandler("", h)
+}
+
+// SetMethodNotAllowedHandler 设置Group路径下的405处理，Group的中间件同样生效
+func (g *group) SetMethodNotAllowedHandler(h HandlerFunc) {
+	g.SetPrefixMethodNotAllowedHandler("", h)
+}
+
+// SetHttpErrorHandler 设置Group所在Muxer的错误处理
+func (g *group) SetHttpErrorHandler(h HttpErrorHandler) {
+	if er, ok := g.Assembler.(ErrorRegister); ok {
+		er.SetHttpErrorHandler(h)
+	}
+}
+
+// SetPrefixNotFoundHandler Assembler没有实现PrefixErrorRegister时不做任何处理
+func (g *group) SetPrefixNotFoundHandler(prefix string, h HandlerFunc) {
+	if per, ok := g.Assembler.(PrefixErrorRegister); ok {
+		per.SetPrefixNotFoundHandler(g.prefix+prefix, g.merge(h))
+	}
+}
+
+// SetPrefixMethodNotAllowedHandler Assembler没有实现PrefixErrorRegister时不做任何处理
+func (g *group) SetPrefixMethodNotAllowedHandler(prefix string, h HandlerFunc) {
+	if per, ok := g.Assembler.(PrefixErrorRegister); ok {
+		per.SetPrefixMethodNotAllowedHandler(g.prefix+prefix, g.merge(h))
+	}
+}
+
+// merge 执行时合并Group的中间件，保证之后Use的中间件同样生效
+func (g *group) merge(h HandlerFunc) HandlerFunc {
+	return func(c Ctx) error {
+		return Merge(h, g.m)(c)
+	}
 }
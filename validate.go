@@ -0,0 +1,359 @@
+package twig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidatorID Ctx#Validate和BindAndValidate使用的Validator插件ID
+// 使用这个ID注册自定义的Validator可以替换默认的StructValidator
+//
+//	web.UsePlugger(myValidator) // myValidator.ID() == twig.ValidatorID
+const ValidatorID = "_twig_default_validator_"
+
+const validateTag = "validate"
+
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// FieldError 字段校验错误
+type FieldError struct {
+	Field string `json:"field" xml:"field"`
+	Rule  string `json:"rule" xml:"rule"`
+	Param string `json:"param,omitempty" xml:"param,omitempty"`
+	Msg   string `json:"msg" xml:"msg"`
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Msg)
+}
+
+// ValidationErrors 校验失败的字段列表
+type ValidationErrors []*FieldError
+
+func (es ValidationErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validationMsg BindAndValidate返回的错误信息
+type validationMsg struct {
+	XMLName xml.Name         `json:"-" xml:"error"`
+	Msg     string           `json:"msg" xml:"msg"`
+	Errors  ValidationErrors `json:"errors" xml:"errors>error"`
+}
+
+// rule 字段上的一条校验规则
+type rule struct {
+	name  string
+	param string
+	re    *regexp.Regexp
+	num   float64
+}
+
+// fieldRules 字段和字段上的规则
+type fieldRules struct {
+	index    int
+	name     string
+	embedded bool // 匿名嵌入的结构体，字段名称不加前缀
+	required bool
+	rules    []rule
+}
+
+// StructValidator 默认的Validator，使用结构体的validate tag声明规则，多条规则用逗号分隔
+//
+//	type User struct {
+//		Name  string `json:"name" validate:"required,min=2,max=32"`
+//		Email string `json:"email" validate:"required,email"`
+//		Role  string `json:"role" validate:"oneof=admin user"`
+//		Code  string `json:"code" validate:"regexp=^[A-Z]{3}$"`
+//	}
+//
+// 支持的规则：required, min, max, len, regexp, oneof, email
+// min, max, len 对数字比较数值，对字符串、slice和map比较长度
+// regexp 会使用余下的全部tag内容作为正则表达式，因此需要放在最后
+// 非required的字段为零值时跳过其他规则，嵌套的结构体和结构体slice会被递归校验
+type StructValidator struct {
+	cache sync.Map // reflect.Type -> []*fieldRules
+}
+
+// NewStructValidator 创建默认的Validator
+func NewStructValidator() *StructValidator {
+	return &StructValidator{}
+}
+
+func (v *StructValidator) ID() string {
+	return ValidatorID
+}
+
+// Validate 校验i，i为结构体或者结构体指针，失败时返回ValidationErrors
+func (v *StructValidator) Validate(i interface{}) error {
+	val := reflect.ValueOf(i)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if err := v.validateStruct(val, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *StructValidator) validateStruct(val reflect.Value, prefix string, errs *ValidationErrors) error {
+	frs, err := v.fieldRules(val.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, fr := range frs {
+		fv := indirect(val.Field(fr.index))
+		if fr.embedded {
+			if fv.IsValid() {
+				if err := v.validateStruct(fv, prefix, errs); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		name := fr.name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		if !fv.IsValid() || fv.IsZero() {
+			if fr.required {
+				*errs = append(*errs, &FieldError{Field: name, Rule: "required", Msg: "is required"})
+			}
+			continue
+		}
+
+		for _, r := range fr.rules {
+			if fe := r.check(fv); fe != nil {
+				fe.Field = name
+				*errs = append(*errs, fe)
+			}
+		}
+
+		if err := v.dive(fv, name, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dive 递归校验嵌套的结构体
+func (v *StructValidator) dive(fv reflect.Value, name string, errs *ValidationErrors) error {
+	switch fv.Kind() {
+	case reflect.Struct:
+		return v.validateStruct(fv, name, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if ev := indirect(fv.Index(i)); ev.IsValid() && ev.Kind() == reflect.Struct {
+				if err := v.validateStruct(ev, fmt.Sprintf("%s[%d]", name, i), errs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fieldRules 解析并缓存结构体的校验规则
+func (v *StructValidator) fieldRules(typ reflect.Type) ([]*fieldRules, error) {
+	if frs, ok := v.cache.Load(typ); ok {
+		return frs.([]*fieldRules), nil
+	}
+
+	var frs []*fieldRules
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get(validateTag)
+		if f.Anonymous && tag == "" && fieldName(f) == f.Name {
+			if t := f.Type; t.Kind() == reflect.Struct || t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+				frs = append(frs, &fieldRules{index: i, embedded: true})
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		fr := &fieldRules{index: i, name: fieldName(f)}
+		if err := fr.parse(tag); err != nil {
+			return nil, fmt.Errorf("twig: field %s.%s: %v", typ.Name(), f.Name, err)
+		}
+		frs = append(frs, fr)
+	}
+
+	v.cache.Store(typ, frs)
+	return frs, nil
+}
+
+func (fr *fieldRules) parse(tag string) error {
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regexp=") {
+			part, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+
+		name, param := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, param = part[:i], part[i+1:]
+		}
+
+		r := rule{name: name, param: param}
+		switch name {
+		case "":
+			continue
+		case "required":
+			fr.required = true
+			continue
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s param %q", name, param)
+			}
+			r.num = n
+		case "regexp":
+			re, err := regexp.Compile(param)
+			if err != nil {
+				return err
+			}
+			r.re = re
+		case "oneof", "email":
+		default:
+			return fmt.Errorf("unknown validate rule %q", name)
+		}
+		fr.rules = append(fr.rules, r)
+	}
+	return nil
+}
+
+// check 校验v，v为非零值
+func (r rule) check(v reflect.Value) *FieldError {
+	switch r.name {
+	case "min", "max", "len":
+		n, isLen, ok := measure(v)
+		if !ok {
+			return nil
+		}
+		unit := ""
+		if isLen {
+			unit = "length "
+		}
+		switch {
+		case r.name == "min" && n < r.num:
+			return r.fail(fmt.Sprintf("%smust be at least %s", unit, r.param))
+		case r.name == "max" && n > r.num:
+			return r.fail(fmt.Sprintf("%smust be at most %s", unit, r.param))
+		case r.name == "len" && n != r.num:
+			return r.fail(fmt.Sprintf("%smust be %s", unit, r.param))
+		}
+	case "regexp":
+		if v.Kind() == reflect.String && !r.re.MatchString(v.String()) {
+			return r.fail("must match " + r.param)
+		}
+	case "email":
+		if v.Kind() == reflect.String && !emailRegexp.MatchString(v.String()) {
+			return r.fail("must be a valid email address")
+		}
+	case "oneof":
+		s := fmt.Sprint(v)
+		for _, o := range strings.Fields(r.param) {
+			if o == s {
+				return nil
+			}
+		}
+		return r.fail("must be one of [" + r.param + "]")
+	}
+	return nil
+}
+
+func (r rule) fail(msg string) *FieldError {
+	return &FieldError{Rule: r.name, Param: r.param, Msg: msg}
+}
+
+// measure 返回用于min/max/len比较的数值，isLen表示是否为长度
+func measure(v reflect.Value) (n float64, isLen bool, ok bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	}
+	return 0, false, false
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fieldName 校验错误中使用的字段名称，依次使用json, form, query tag和字段名
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "query"} {
+		if name := strings.Split(f.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Validate 使用ID为ValidatorID的Validator校验i
+func Validate(i interface{}, c Ctx) error {
+	validator := GetValidator(ValidatorID, c)
+	if validator == nil {
+		return ErrValidatorNotRegistered
+	}
+	return validator.Validate(i)
+}
+
+// BindAndValidate 绑定并校验i，校验失败时返回422错误，Msg中包含每个字段的错误
+func BindAndValidate(i interface{}, c Ctx) error {
+	if err := Bind(i, c); err != nil {
+		return err
+	}
+
+	err := Validate(i, c)
+	if errs, ok := err.(ValidationErrors); ok {
+		return NewHttpError(http.StatusUnprocessableEntity, &validationMsg{
+			Msg:    http.StatusText(http.StatusUnprocessableEntity),
+			Errors: errs,
+		}).SetInternal(err)
+	}
+	return err
+}
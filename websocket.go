@@ -0,0 +1,732 @@
+package twig
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket消息类型，见RFC 6455
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// WebSocket关闭码
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	upgraderID = "_twig_websocket_upgrader_"
+
+	wsAcceptGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsDeflateExtension = "permessage-deflate"
+
+	defaultWSReadLimit  = 32 << 20
+	defaultWSBufferSize = 4096
+
+	maxControlPayload = 125
+)
+
+// deflate压缩后需要去掉，解压前需要补上的结尾
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+var (
+	// ErrWSReadLimit 消息超过读取限制
+	ErrWSReadLimit = errors.New("websocket: read limit exceeded")
+	// ErrWSClosed 连接已经关闭
+	ErrWSClosed = errors.New("websocket: use of closed connection")
+)
+
+// CloseError 收到对方的关闭帧或者因为协议错误关闭连接
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError err是否为codes中的关闭码，没有codes时只判断是否为CloseError
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Upgrader WebSocket握手的配置，作为插件替换Ctx#Upgrade使用的默认配置
+//
+//	web.UsePlugger(&twig.Upgrader{ReadLimit: 1 << 20, EnableCompression: true})
+//	web.Config().Get("/ws", func(c twig.Ctx) error {
+//		ws, err := c.Upgrade()
+//		if err != nil {
+//			return err
+//		}
+//		defer ws.Close()
+//		for {
+//			typ, msg, err := ws.ReadMessage()
+//			if err != nil {
+//				return nil
+//			}
+//			if err = ws.WriteMessage(typ, msg); err != nil {
+//				return nil
+//			}
+//		}
+//	}, middleware.JWTWithConfig(config))
+type Upgrader struct {
+	// ReadLimit 单个消息的最大字节数(解压后)，小于等于0时使用默认的32MB，不能取消限制
+	ReadLimit int64
+	// ReadBufferSize WriteBufferSize 读写缓冲区大小，默认4096
+	ReadBufferSize  int
+	WriteBufferSize int
+	// Subprotocols 服务器支持的子协议，按照客户端的顺序选择第一个支持的
+	Subprotocols []string
+	// CheckOrigin 检查Origin，默认要求Origin的Host和请求的Host相同
+	CheckOrigin func(*http.Request) bool
+	// EnableCompression 支持permessage-deflate压缩(不使用上下文接管)
+	EnableCompression bool
+}
+
+func (u *Upgrader) ID() string {
+	return upgraderID
+}
+
+// upgrader 获取注册的Upgrader，没有时使用默认配置
+func upgrader(c Ctx) *Upgrader {
+	if u, ok := GetPlugger(upgraderID, c).(*Upgrader); ok {
+		return u
+	}
+	return &Upgrader{}
+}
+
+// Upgrade 将当前请求升级为WebSocket连接，见Upgrader
+func Upgrade(c Ctx) (*WSConn, error) {
+	return upgrader(c).Upgrade(c)
+}
+
+// Upgrade 完成WebSocket握手，失败时返回HttpError，没有输出任何内容
+// 成功后响应已经提交，Handler返回的错误不会再输出
+func (u *Upgrader) Upgrade(c Ctx) (*WSConn, error) {
+	r := c.Req()
+	if r.Method != http.MethodGet {
+		return nil, NewHttpError(http.StatusMethodNotAllowed, "websocket: method not GET")
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, HeaderUpgrade, "websocket") {
+		return nil, NewHttpError(http.StatusBadRequest, "websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Resp().Header().Set("Sec-WebSocket-Version", "13")
+		return nil, NewHttpError(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		return nil, NewHttpError(http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, NewHttpError(http.StatusForbidden, "websocket: origin not allowed")
+	}
+
+	resp := c.Resp()
+	if resp.Committed {
+		return nil, errors.New("websocket: response already committed")
+	}
+	netConn, brw, err := resp.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// 清除Server设置的超时
+	netConn.SetDeadline(time.Time{})
+
+	subprotocol := u.selectSubprotocol(r)
+	compress := u.EnableCompression && acceptDeflate(r.Header)
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	buf.WriteString(acceptKey(key))
+	buf.WriteString("\r\n")
+	if subprotocol != "" {
+		buf.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		buf.WriteString("Sec-WebSocket-Extensions: " + wsDeflateExtension + "; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	buf.WriteString("\r\n")
+
+	if _, err = netConn.Write(buf.Bytes()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	resp.Status = http.StatusSwitchingProtocols
+	resp.Committed = true
+
+	br := brw.Reader
+	if u.ReadBufferSize > 0 && br.Buffered() == 0 {
+		br = bufio.NewReaderSize(netConn, u.ReadBufferSize)
+	}
+	wsize := u.WriteBufferSize
+	if wsize <= 0 {
+		wsize = defaultWSBufferSize
+	}
+	limit := u.ReadLimit
+	if limit <= 0 {
+		limit = defaultWSReadLimit
+	}
+
+	ws := &WSConn{
+		conn:        netConn,
+		br:          br,
+		bw:          bufio.NewWriterSize(netConn, wsize),
+		readLimit:   limit,
+		subprotocol: subprotocol,
+		compress:    compress,
+	}
+	ws.pingHandler = ws.defaultPingHandler
+	return ws, nil
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	for _, p := range headerTokens(r.Header, "Sec-WebSocket-Protocol") {
+		for _, s := range u.Subprotocols {
+			if p == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// sameOrigin 没有Origin或者Origin的Host与请求的Host相同
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	i := strings.Index(origin, "://")
+	if i < 0 {
+		return false
+	}
+	return strings.EqualFold(origin[i+3:], r.Host)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// acceptDeflate 客户端是否提供了可以接受的permessage-deflate
+// 不支持server_max_window_bits小于15的请求
+func acceptDeflate(h http.Header) bool {
+	for _, ext := range headerTokens(h, "Sec-WebSocket-Extensions") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != wsDeflateExtension {
+			continue
+		}
+		ok := true
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "server_max_window_bits") && strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(p, "server_max_window_bits"), "=")) != "15" {
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// headerTokens 使用逗号拆分header的值
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// WSConn WebSocket连接
+// ReadMessage只能在一个goroutine中调用，写操作可以在多个goroutine中调用
+type WSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	wlock sync.Mutex
+	bw    *bufio.Writer
+	fw    *flate.Writer
+
+	readLimit   int64
+	subprotocol string
+	compress    bool
+
+	closeSent bool
+
+	pingHandler func(string) error
+	pongHandler func(string) error
+}
+
+// Subprotocol 协商的子协议
+func (ws *WSConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// NetConn 底层的连接
+func (ws *WSConn) NetConn() net.Conn {
+	return ws.conn
+}
+
+func (ws *WSConn) LocalAddr() net.Addr {
+	return ws.conn.LocalAddr()
+}
+
+func (ws *WSConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SetReadLimit 设置单个消息的最大字节数，小于等于0时使用默认的32MB
+// 读取限制总是存在，避免按照对方声明的长度分配过大的内存
+func (ws *WSConn) SetReadLimit(limit int64) {
+	if limit <= 0 {
+		limit = defaultWSReadLimit
+	}
+	ws.readLimit = limit
+}
+
+func (ws *WSConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+func (ws *WSConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler 收到Ping时调用，默认回复Pong
+func (ws *WSConn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = ws.defaultPingHandler
+	}
+	ws.pingHandler = h
+}
+
+// SetPongHandler 收到Pong时调用，例如用于延长读取超时
+func (ws *WSConn) SetPongHandler(h func(appData string) error) {
+	ws.pongHandler = h
+}
+
+func (ws *WSConn) defaultPingHandler(data string) error {
+	err := ws.WriteControl(PongMessage, []byte(data))
+	if err == ErrWSClosed {
+		return nil
+	}
+	return err
+}
+
+// frame 读取到的帧
+type frame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+// ReadMessage 读取一个完整的消息，自动处理分片和控制帧
+// 收到关闭帧时回复关闭帧并返回CloseError
+func (ws *WSConn) ReadMessage() (messageType int, p []byte, err error) {
+	var (
+		msg        []byte
+		typ        int
+		compressed bool
+	)
+
+	for {
+		f, err := ws.readFrame(int64(len(msg)))
+		if err != nil {
+			return 0, nil, ws.readErr(err)
+		}
+
+		switch f.opcode {
+		case PingMessage:
+			if err := ws.pingHandler(string(f.payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if ws.pongHandler != nil {
+				if err := ws.pongHandler(string(f.payload)); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, ws.handleClose(f.payload)
+		case TextMessage, BinaryMessage:
+			if typ != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected data frame")
+			}
+			typ, compressed = f.opcode, f.rsv1
+		case continuationFrame:
+			if typ == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+			if f.rsv1 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected rsv1")
+			}
+		}
+
+		msg = append(msg, f.payload...)
+		if !f.fin {
+			continue
+		}
+
+		if compressed {
+			if msg, err = ws.inflate(msg); err != nil {
+				return 0, nil, ws.readErr(err)
+			}
+		}
+		if typ == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, ws.fail(CloseInvalidFramePayloadData, "invalid utf8")
+		}
+		return typ, msg, nil
+	}
+}
+
+// readFrame 读取一帧，read为当前消息已经读取的字节数
+func (ws *WSConn) readFrame(read int64) (*frame, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(ws.br, h[:]); err != nil {
+		return nil, err
+	}
+
+	f := &frame{
+		fin:    h[0]&0x80 != 0,
+		rsv1:   h[0]&0x40 != 0,
+		opcode: int(h[0] & 0x0f),
+	}
+	if h[0]&0x30 != 0 || f.rsv1 && !ws.compress {
+		return nil, ws.fail(CloseProtocolError, "unexpected reserved bits")
+	}
+	if h[1]&0x80 == 0 {
+		return nil, ws.fail(CloseProtocolError, "client frame not masked")
+	}
+
+	control := f.opcode >= CloseMessage
+	switch f.opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !f.fin || f.rsv1 {
+			return nil, ws.fail(CloseProtocolError, "invalid control frame")
+		}
+	default:
+		return nil, ws.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+	}
+
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return nil, err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return nil, err
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+		if n < 0 {
+			return nil, ws.fail(CloseProtocolError, "invalid payload length")
+		}
+	}
+
+	if control && n > maxControlPayload {
+		return nil, ws.fail(CloseProtocolError, "control frame too long")
+	}
+	if !control && n > ws.readLimit-read {
+		ws.fail(CloseMessageTooBig, "")
+		return nil, ErrWSReadLimit
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return nil, err
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(ws.br, f.payload); err != nil {
+		return nil, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// inflate 解压消息，解压后的大小同样受到读取限制
+func (ws *WSConn) inflate(msg []byte) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(msg), bytes.NewReader(deflateTail)))
+	defer fr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(fr, ws.readLimit+1))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, ws.fail(CloseInvalidFramePayloadData, "invalid compressed data")
+	}
+	if int64(len(out)) > ws.readLimit {
+		ws.fail(CloseMessageTooBig, "")
+		return nil, ErrWSReadLimit
+	}
+	return out, nil
+}
+
+// handleClose 处理关闭帧，回复关闭帧并关闭连接
+func (ws *WSConn) handleClose(payload []byte) error {
+	ce := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return ws.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Text = string(payload[2:])
+		if !validCloseCode(ce.Code) {
+			return ws.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(ce.Text) {
+			return ws.fail(CloseInvalidFramePayloadData, "invalid utf8")
+		}
+	}
+
+	// 回复对方的关闭码
+	var reply []byte
+	if ce.Code != CloseNoStatusReceived {
+		reply = payload[:2]
+	}
+	ws.WriteControl(CloseMessage, reply)
+	ws.conn.Close()
+	return ce
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail 发送关闭帧并关闭连接
+func (ws *WSConn) fail(code int, text string) error {
+	ws.WriteControl(CloseMessage, closePayload(code, text))
+	ws.conn.Close()
+	return &CloseError{Code: code, Text: text}
+}
+
+// readErr 读取错误，连接中断时返回CloseAbnormalClosure
+func (ws *WSConn) readErr(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		ws.conn.Close()
+		return &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+	}
+	return err
+}
+
+func closePayload(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	if len(text) > maxControlPayload-2 {
+		text = text[:maxControlPayload-2]
+	}
+	p := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(p, uint16(code))
+	copy(p[2:], text)
+	return p
+}
+
+// WriteMessage 发送一个完整的消息，messageType为TextMessage或者BinaryMessage时可能被压缩
+// 也可以发送控制消息，见WriteControl
+func (ws *WSConn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		return ws.WriteControl(messageType, data)
+	default:
+		return fmt.Errorf("websocket: unknown message type %d", messageType)
+	}
+
+	ws.wlock.Lock()
+	defer ws.wlock.Unlock()
+
+	if ws.compress {
+		compressed, err := ws.deflate(data)
+		if err != nil {
+			return err
+		}
+		return ws.writeFrame(messageType, true, compressed)
+	}
+	return ws.writeFrame(messageType, false, data)
+}
+
+// WriteJSON 以文本消息发送v的JSON
+func (ws *WSConn) WriteJSON(v interface{}) error {
+	var buf bytes.Buffer
+	if err := JSONCodec.Encode(&buf, v); err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+// ReadJSON 读取一个消息并解析JSON到v
+func (ws *WSConn) ReadJSON(v interface{}) error {
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return JSONCodec.Decode(bytes.NewReader(msg), v)
+}
+
+// deflate 压缩消息，每个消息单独压缩
+func (ws *WSConn) deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if ws.fw == nil {
+		fw, err := flate.NewWriter(&buf, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		ws.fw = fw
+	} else {
+		ws.fw.Reset(&buf)
+	}
+	if _, err := ws.fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := ws.fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// WriteControl 发送Close、Ping或者Pong控制消息，发送Close后不能再发送任何消息
+func (ws *WSConn) WriteControl(messageType int, data []byte) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control payload too long")
+	}
+
+	ws.wlock.Lock()
+	defer ws.wlock.Unlock()
+	return ws.writeFrame(messageType, false, data)
+}
+
+// Ping 发送Ping
+func (ws *WSConn) Ping(data []byte) error {
+	return ws.WriteControl(PingMessage, data)
+}
+
+// writeFrame 发送一帧，调用前需要持有wlock，服务器发送的帧不使用掩码
+func (ws *WSConn) writeFrame(opcode int, rsv1 bool, payload []byte) error {
+	if ws.closeSent {
+		return ErrWSClosed
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+
+	var h [10]byte
+	h[0] = 0x80 | byte(opcode)
+	if rsv1 {
+		h[0] |= 0x40
+	}
+	n := 2
+	switch l := len(payload); {
+	case l <= 125:
+		h[1] = byte(l)
+	case l <= 0xffff:
+		h[1] = 126
+		binary.BigEndian.PutUint16(h[2:], uint16(l))
+		n += 2
+	default:
+		h[1] = 127
+		binary.BigEndian.PutUint64(h[2:], uint64(l))
+		n += 8
+	}
+
+	if _, err := ws.bw.Write(h[:n]); err != nil {
+		return err
+	}
+	if _, err := ws.bw.Write(payload); err != nil {
+		return err
+	}
+	return ws.bw.Flush()
+}
+
+// CloseWithCode 发送关闭帧后关闭连接
+func (ws *WSConn) CloseWithCode(code int, text string) error {
+	ws.WriteControl(CloseMessage, closePayload(code, text))
+	return ws.conn.Close()
+}
+
+// Close 使用CloseNormalClosure关闭连接
+func (ws *WSConn) Close() error {
+	return ws.CloseWithCode(CloseNormalClosure, "")
+}